@@ -0,0 +1,234 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/app/internal/keymap"
+)
+
+// ReadInputrc applies the key bindings and settings of an inputrc file.
+func (r *Repl) ReadInputrc(path string) []error {
+	file, err := os.Open(path)
+	if err != nil {
+		return []error{err}
+	}
+	defer file.Close()
+
+	mode, errs := r.Keymaps.Read(file, path, r.options.Vi)
+	if mode != "" {
+		r.options.set(mode, true)
+	}
+	return errs
+}
+
+// Bind is the bind builtin. Its arguments are bindings as inputrc writes
+// them, `"\C-a": beginning-of-line`, or `set` lines. -x binds a key to a
+// shell command instead, -r removes a binding, -f reads an inputrc file
+// and -u unbinds the keys of an action. -l lists the actions, -p and -P
+// the bindings, -X the shell command bindings and -q the keys of one
+// action. They all apply to the keymap of the current editing mode, or to
+// the one -m names.
+type Bind struct {
+	repl *Repl
+}
+
+func (b *Bind) Run(args []string) int {
+	km := b.modeKeymap()
+	status := 0
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		if arg == "--" {
+			for _, line := range args[i+1:] {
+				status = max(status, b.bindLine(km, line))
+			}
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			status = max(status, b.bindLine(km, arg))
+			continue
+		}
+
+		for j := 1; j < len(arg); j++ {
+			flag := arg[j]
+
+			switch flag {
+			case 'l':
+				b.repl.Print(strings.Join(keymap.Actions, "\n") + "\n")
+				continue
+			case 'p':
+				b.printBindings(km)
+				continue
+			case 'P':
+				b.describeBindings(km)
+				continue
+			case 'X':
+				b.printCommands(km)
+				continue
+			case 'm', 'x', 'r', 'f', 'q', 'u':
+			default:
+				b.repl.PrintError(fmt.Sprintf("bind: -%c: invalid option", flag))
+				return 2
+			}
+
+			// The rest of the argument, or the next one, is the value
+			value := arg[j+1:]
+			if value == "" {
+				if i+1 == len(args) {
+					b.repl.PrintError(fmt.Sprintf("bind: -%c: option requires an argument", flag))
+					return 2
+				}
+				i++
+				value = args[i]
+			}
+			j = len(arg)
+
+			switch flag {
+			case 'm':
+				named, ok := b.repl.Keymaps.Get(value)
+				if !ok {
+					b.repl.PrintError(fmt.Sprintf("bind: %s: invalid keymap name", value))
+					return 1
+				}
+				km = named
+			case 'x':
+				seq, binding, err := keymap.ParseBinding(value, true)
+				if err != nil {
+					b.repl.PrintError(fmt.Sprintf("bind: %v", err))
+					status = 1
+					continue
+				}
+				km.Bind(seq, binding)
+			case 'r':
+				seq, err := keymap.ParseKeySeq(value)
+				if err != nil {
+					b.repl.PrintError(fmt.Sprintf("bind: %v", err))
+					status = 1
+					continue
+				}
+				km.Unbind(seq)
+			case 'f':
+				for _, err := range b.repl.ReadInputrc(value) {
+					b.repl.PrintError(fmt.Sprintf("bind: %v", err))
+					status = 1
+				}
+			case 'q':
+				status = max(status, b.query(km, value))
+			case 'u':
+				if !keymap.IsAction(value) {
+					b.repl.PrintError(fmt.Sprintf("bind: %s: unknown function name", value))
+					status = 1
+					continue
+				}
+				for _, seq := range km.KeysFor(value) {
+					km.Unbind(seq)
+				}
+			}
+		}
+	}
+
+	return status
+}
+
+// modeKeymap is the keymap typing goes through in the current editing
+// mode.
+func (b *Bind) modeKeymap() *keymap.Keymap {
+	name := "emacs"
+	if b.repl.options.Vi {
+		name = "vi-insert"
+	}
+	km, _ := b.repl.Keymaps.Get(name)
+	return km
+}
+
+// bindLine applies a binding or a `set` line and returns the status.
+func (b *Bind) bindLine(km *keymap.Keymap, line string) int {
+	if strings.HasPrefix(strings.TrimSpace(line), "set ") {
+		mode, errs := b.repl.Keymaps.Read(strings.NewReader(line), "bind", b.repl.options.Vi)
+		if mode != "" {
+			b.repl.options.set(mode, true)
+		}
+		for _, err := range errs {
+			b.repl.PrintError(fmt.Sprintf("bind: %v", err))
+		}
+		if len(errs) > 0 {
+			return 1
+		}
+		return 0
+	}
+
+	seq, binding, err := keymap.ParseBinding(line, false)
+	if err != nil {
+		b.repl.PrintError(fmt.Sprintf("bind: %v", err))
+		return 1
+	}
+	km.Bind(seq, binding)
+	return 0
+}
+
+// printBindings lists the actions and macros bound in km the way inputrc
+// writes them, for `bind -p`.
+func (b *Bind) printBindings(km *keymap.Keymap) {
+	var sb strings.Builder
+	for _, seq := range km.Sequences() {
+		binding, _ := km.Lookup(seq)
+		switch {
+		case binding.Action != "":
+			fmt.Fprintf(&sb, "\"%s\": %s\n", keymap.Format(seq), binding.Action)
+		case binding.Macro != "":
+			fmt.Fprintf(&sb, "\"%s\": \"%s\"\n", keymap.Format(seq), keymap.Format(binding.Macro))
+		}
+	}
+	b.repl.Print(sb.String())
+}
+
+// describeBindings lists the keys of every action in words, for `bind -P`.
+func (b *Bind) describeBindings(km *keymap.Keymap) {
+	var sb strings.Builder
+	for _, action := range keymap.Actions {
+		sb.WriteString(describeKeys(km, action))
+		sb.WriteString("\n")
+	}
+	b.repl.Print(sb.String())
+}
+
+// printCommands lists the shell command bindings, for `bind -X`.
+func (b *Bind) printCommands(km *keymap.Keymap) {
+	var sb strings.Builder
+	for _, seq := range km.Sequences() {
+		if binding, _ := km.Lookup(seq); binding.Command != "" {
+			fmt.Fprintf(&sb, "\"%s\": \"%s\"\n", keymap.Format(seq), binding.Command)
+		}
+	}
+	b.repl.Print(sb.String())
+}
+
+// query tells which keys run action, for `bind -q`.
+func (b *Bind) query(km *keymap.Keymap, action string) int {
+	if !keymap.IsAction(action) {
+		b.repl.PrintError(fmt.Sprintf("bind: %s: unknown function name", action))
+		return 1
+	}
+
+	b.repl.Print(describeKeys(km, action) + "\n")
+	if len(km.KeysFor(action)) == 0 {
+		return 1
+	}
+	return 0
+}
+
+func describeKeys(km *keymap.Keymap, action string) string {
+	seqs := km.KeysFor(action)
+	if len(seqs) == 0 {
+		return action + " is not bound to any keys."
+	}
+
+	quoted := make([]string, len(seqs))
+	for i, seq := range seqs {
+		quoted[i] = "\"" + keymap.Format(seq) + "\""
+	}
+	return action + " can be found on " + strings.Join(quoted, ", ") + "."
+}
@@ -5,26 +5,65 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
+	"syscall"
 
 	"github.com/codecrafters-io/shell-starter-go/app/internal/autocompletition"
+	"github.com/codecrafters-io/shell-starter-go/app/internal/jobs"
+	"github.com/codecrafters-io/shell-starter-go/app/internal/keymap"
 	"github.com/codecrafters-io/shell-starter-go/app/internal/output"
 )
 
 type Cmd interface {
-	Run(args []string)
+	Run(args []string) int
 }
 
 type Repl struct {
 	osCmds        map[string]string
+	input         io.Reader
 	output        output.Output
 	errorOutput   output.Output
 	channelOutput *output.ChannelOutput
 	trieNode      *autocompletition.TrieNode
 	History       *History
+	Jobs          *jobs.Table
+	Keymaps       *keymap.Keymaps
+	lastStatus    int
+	exitHooks     []func()
+	// positional holds $0 followed by $1 onwards
+	positional []string
+	options    Options
+	// vars are the shell variables that are not exported; exported ones
+	// live in the environment
+	vars map[string]string
+	// exported are the names exported before they were set
+	exported map[string]bool
 }
 
 func InitRepl() *Repl {
+	repl := &Repl{
+		input:       os.Stdin,
+		output:      output.NewOutput(false),
+		errorOutput: output.NewOutput(true),
+		trieNode:    autocompletition.InitTrieNode(),
+		History:     InitHistory(),
+		Jobs:        jobs.NewTable(),
+		Keymaps:     keymap.New(),
+		positional:  []string{os.Args[0]},
+		vars:        make(map[string]string),
+		exported:    make(map[string]bool),
+	}
+	repl.loadCommands()
+
+	repl.OnExit(repl.Jobs.HangUp)
+
+	return repl
+}
+
+// loadCommands finds the commands in PATH, for running and completing them.
+// The completion trie is rebuilt in place, the line editor holds on to it.
+func (r *Repl) loadCommands() {
 	pathEnv := os.Getenv("PATH")
 	pathArr := strings.Split(pathEnv, ":")
 
@@ -34,7 +73,6 @@ func InitRepl() *Repl {
 	for _, dirPath := range pathArr {
 		files, err := os.ReadDir(dirPath)
 		if err != nil {
-			fmt.Errorf("error during reading dir: %v \n", err.Error())
 			continue
 		}
 
@@ -51,30 +89,80 @@ func InitRepl() *Repl {
 
 	wholeCmdsArray := append(osCmdsArray, AvailableCmds...)
 
-	// Initialize the TrieNode for autocomplete
-	rootNode := autocompletition.InitTrieNode()
-	rootNode.LoadWordsToTrie(wholeCmdsArray)
-
-	return &Repl{
-		osCmds:        osCmds,
-		output:        output.NewOutput(false),
-		errorOutput:   output.NewOutput(true),
-		channelOutput: nil,
-		trieNode:      rootNode,
-		History:       InitHistory(),
+	r.osCmds = osCmds
+	*r.trieNode = *autocompletition.InitTrieNode()
+	r.trieNode.LoadWordsToTrie(wholeCmdsArray)
+}
+
+// SetLastStatus records the exit status of the most recently run pipeline.
+func (r *Repl) SetLastStatus(status int) {
+	r.lastStatus = status
+}
+
+func (r *Repl) LastStatus() int {
+	return r.lastStatus
+}
+
+// Positional returns $0 followed by $1 onwards.
+func (r *Repl) Positional() []string {
+	return append([]string{}, r.positional...)
+}
+
+// SetPositional sets $0 to name and $1 onwards to params.
+func (r *Repl) SetPositional(name string, params []string) {
+	r.positional = append([]string{name}, params...)
+}
+
+// LookupVar resolves shell parameters and variables for expansion, falling
+// back to the process environment.
+func (r *Repl) LookupVar(name string) (string, bool) {
+	switch name {
+	case "?":
+		return strconv.Itoa(r.lastStatus), true
+	case "#":
+		return strconv.Itoa(len(r.positional) - 1), true
+	case "@", "*":
+		return strings.Join(r.positional[1:], " "), true
+	}
+
+	if n, err := strconv.Atoi(name); err == nil && n >= 0 {
+		if n < len(r.positional) {
+			return r.positional[n], true
+		}
+		return "", false
+	}
+
+	if value, ok := r.vars[name]; ok {
+		return value, true
 	}
+	return os.LookupEnv(name)
 }
 
+// ResetOutput points the repl back at the terminal for stdin, stdout and
+// stderr after a command redirected them.
 func (r *Repl) ResetOutput() {
+	r.input = os.Stdin
 	r.output = output.NewOutput(false)
 	r.errorOutput = output.NewOutput(true)
 	r.channelOutput = nil
 }
 
+func (r *Repl) SetInput(input io.Reader) {
+	r.input = input
+}
+
+func (r *Repl) GetInput() io.Reader {
+	return r.input
+}
+
 func (r *Repl) SetOutput(output output.Output) {
 	r.output = output
 }
 
+func (r *Repl) SetErrorOutput(errorOutput output.Output) {
+	r.errorOutput = errorOutput
+}
+
 func (r *Repl) RedirectStdOutToChannel(channelOutput *output.ChannelOutput) {
 	r.channelOutput = channelOutput
 	r.output = channelOutput
@@ -102,14 +190,6 @@ func (r *Repl) ShowCmds() {
 	r.trieNode.Display(0)
 }
 
-func (r *Repl) RedirectStdOutToFile(fileName string, append bool) {
-	r.output = output.NewFileOutput(fileName, append)
-}
-
-func (r *Repl) RedirectStdErrToFile(fileName string, append bool) {
-	r.errorOutput = output.NewFileOutput(fileName, append)
-}
-
 func (r *Repl) PrintError(msg string) {
 	r.errorOutput.PrintError(fmt.Sprintf("%s", msg))
 }
@@ -118,23 +198,45 @@ func (r *Repl) Print(msg string) {
 	r.output.Print(msg)
 }
 
+// CmdExist finds an external command. A name with a slash is a path to an
+// executable file rather than a name looked up in PATH.
 func (r *Repl) CmdExist(cmdName string) (string, bool) {
+	if strings.Contains(cmdName, "/") {
+		info, err := os.Stat(cmdName)
+		if err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+			return "", false
+		}
+		return cmdName, true
+	}
+
 	path, ok := r.osCmds[cmdName]
 
 	return path, ok
 }
 
-func (r *Repl) Pwd() {
+func (r *Repl) Pwd() int {
 	absPath, err := os.Getwd()
 	if err != nil {
 		r.PrintError(fmt.Sprintf("error during running: %v", err.Error()))
-		return
+		return 1
 	}
 
 	r.Print(fmt.Sprintf("%s\n", absPath))
+	return 0
 }
 
-func (r *Repl) Cd(path string) {
+// Cd changes to the directory in args, or to $HOME without one.
+func (r *Repl) Cd(args []string) int {
+	if len(args) == 0 {
+		home, ok := os.LookupEnv("HOME")
+		if !ok {
+			r.PrintError("cd: HOME not set")
+			return 1
+		}
+		args = []string{home}
+	}
+
+	path := args[0]
 	if strings.Contains(path, "~") {
 		homePath := os.Getenv("HOME")
 		path = strings.Replace(path, "~", homePath, 1)
@@ -143,7 +245,10 @@ func (r *Repl) Cd(path string) {
 	err := os.Chdir(path)
 	if err != nil {
 		r.PrintError(fmt.Sprintf("%s: %s: %s", "cd", path, "No such file or directory"))
+		return 1
 	}
+
+	return 0
 }
 
 func (r *Repl) GetTrieNode() *autocompletition.TrieNode {
@@ -154,57 +259,32 @@ func NewCmd(repl *Repl, name string) Cmd {
 	switch name {
 	case "type":
 		return InitType(repl)
+	case "history":
+		return &HistoryCmd{repl: repl}
+	case "jobs":
+		return &ListJobs{repl: repl}
+	case "fg":
+		return &Fg{repl: repl}
+	case "bg":
+		return &Bg{repl: repl}
+	case "wait":
+		return &Wait{repl: repl}
+	case "set":
+		return &Set{repl: repl}
+	case "bind":
+		return &Bind{repl: repl}
+	case "export":
+		return &Export{repl: repl}
 	}
 	return nil
 }
 
-func RunOSCmd(repl *Repl, name string, args []string) {
-	cmd := exec.Command(name, args...)
-
-	// Create pipes for stdout and stderr
-	stdoutPipe, err := cmd.StdoutPipe()
-	if err != nil {
-		repl.PrintError(fmt.Sprintf("error creating stdout pipe: %v", err.Error()))
-		return
+// ExitStatus converts a finished process state into a shell exit status,
+// reporting death by signal as 128+signal like other shells do.
+func ExitStatus(exitErr *exec.ExitError) int {
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
 	}
 
-	stderrPipe, err := cmd.StderrPipe()
-	if err != nil {
-		repl.PrintError(fmt.Sprintf("error creating stderr pipe: %v", err.Error()))
-		return
-	}
-
-	// Start the command before reading from pipes
-	if err := cmd.Start(); err != nil {
-		repl.PrintError(fmt.Sprintf("error starting command: %v", err.Error()))
-		return
-	}
-
-	// Use goroutines to handle stdout and stderr streams
-	stdoutDone := make(chan bool)
-	stderrDone := make(chan bool)
-
-	go func() {
-		repl.output.WriteStream(stdoutPipe)
-		stdoutDone <- true
-	}()
-
-	go func() {
-		repl.errorOutput.WriteStream(stderrPipe)
-		stderrDone <- true
-	}()
-
-	// Wait for both streams to complete
-	<-stdoutDone
-	<-stderrDone
-
-	// Wait for the command to complete
-	if err := cmd.Wait(); err != nil {
-		if _, ok := err.(*exec.ExitError); ok {
-			// Command failed with non-zero exit code
-			// repl.PrintError(fmt.Sprintf("command exited with status %d", exitErr.ExitCode()))
-		} else {
-			repl.PrintError(fmt.Sprintf("error waiting for command: %v", err.Error()))
-		}
-	}
+	return exitErr.ExitCode()
 }
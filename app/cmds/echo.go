@@ -5,6 +5,7 @@ import (
 	"strings"
 )
 
-func Echo(repl *Repl, msg []string) {
+func Echo(repl *Repl, msg []string) int {
 	repl.Print(fmt.Sprintf("%s\n", strings.Join(msg, " ")))
+	return 0
 }
@@ -0,0 +1,46 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// OnExit registers hook to run when the shell exits. Hooks run in reverse
+// order of registration.
+func (r *Repl) OnExit(hook func()) {
+	r.exitHooks = append(r.exitHooks, hook)
+}
+
+// Exit runs the exit hooks and terminates the shell with status, which the
+// hooks see as the last status.
+func (r *Repl) Exit(status int) {
+	r.lastStatus = status
+	for i := len(r.exitHooks) - 1; i >= 0; i-- {
+		r.exitHooks[i]()
+	}
+
+	os.Exit(status)
+}
+
+// ExitArgs works out the status `exit args` asks for: the number given,
+// truncated to 8 bits, or the last status. It reports false, after saying
+// why, when the shell has to keep running.
+func ExitArgs(repl *Repl, args []string) (int, bool) {
+	if len(args) == 0 {
+		return repl.LastStatus(), true
+	}
+
+	status, err := strconv.Atoi(args[0])
+	if err != nil {
+		repl.PrintError(fmt.Sprintf("exit: %s: numeric argument required", args[0]))
+		return 2, true
+	}
+
+	if len(args) > 1 {
+		repl.PrintError("exit: too many arguments")
+		return 1, false
+	}
+
+	return status & 0xff, true
+}
@@ -1,147 +1,597 @@
 package cmds
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 )
 
-var fileName = "history.txt"
+const defaultHistSize = 500
 
+// HistoryEntry is a command line entered interactively, along with where,
+// when and how it ran. Entries are kept in the history file as JSON lines.
+type HistoryEntry struct {
+	Line     string        `json:"line"`
+	Start    time.Time     `json:"start,omitzero"`
+	Dir      string        `json:"cwd,omitempty"`
+	Duration time.Duration `json:"duration_ns,omitempty"`
+	// Status is nil until the command finished
+	Status *int `json:"status,omitempty"`
+}
+
+// Failed reports whether the command finished with a non-zero status.
+func (e *HistoryEntry) Failed() bool {
+	return e.Status != nil && *e.Status != 0
+}
+
+// History keeps the commands entered interactively. They are loaded from the
+// history file at startup and appended to it as they finish. HISTSIZE caps
+// the entries kept in memory and HISTFILESIZE the lines kept in the file.
+//
+// Several shells can share one file: every access holds a lock on it and
+// writes only append, so each session remembers how far into the file it
+// has read to pick up what the others added. Trimming the file replaces it
+// with a new one, which tells the other sessions to find their place again.
 type History struct {
-	nextToWriteIndex int
-	file             *os.File
-	navigationIndex  int
-	lines            []string
+	path            string
+	size            int
+	fileSize        int
+	navigationIndex int
+	entries         []*HistoryEntry
+	// running is the entry of the command being run, if any
+	running *HistoryEntry
+	// unsaved are the finished entries not in the file yet
+	unsaved []*HistoryEntry
+	// offset is how much of the file this session has read or written
+	offset int64
+	// file is the history file offset is into, and lastLine the line that
+	// ends there, to find the place again in a file trimmed meanwhile
+	file     os.FileInfo
+	lastLine string
+	// pending are entries other sessions added that were skipped over while
+	// appending, waiting to be read in
+	pending []*HistoryEntry
+	// fileLines is how many lines the history file holds
+	fileLines int
 }
 
 func InitHistory() *History {
-	flags := os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	size := histSize("HISTSIZE", defaultHistSize)
+
+	return &History{
+		path:     historyPath(),
+		size:     size,
+		fileSize: histSize("HISTFILESIZE", size),
+		entries:  make([]*HistoryEntry, 0),
+	}
+}
+
+// historyPath is $HISTFILE, or the XDG state directory's gosh/history.
+func historyPath() string {
+	if path, ok := os.LookupEnv("HISTFILE"); ok {
+		return path
+	}
 
-	file, err := os.OpenFile(fileName, flags, 0644)
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		stateHome = filepath.Join(home, ".local", "state")
+	}
+
+	return filepath.Join(stateHome, "gosh", "history")
+}
+
+// histSize reads a size variable. A negative value means no limit.
+func histSize(name string, fallback int) int {
+	size, err := strconv.Atoi(os.Getenv(name))
 	if err != nil {
-		fmt.Println("Error opening file:", err)
+		return fallback
+	}
+	return size
+}
+
+// Load reads the history file into memory, so that earlier sessions can be
+// recalled. A missing file is an empty history.
+func (h *History) Load() error {
+	h.entries = h.entries[:0]
+
+	return h.ReadAll()
+}
+
+// Start adds a command line about to run, timed from now in the current
+// directory.
+func (h *History) Start(input string) {
+	entry := &HistoryEntry{Line: strings.TrimSpace(input), Start: time.Now()}
+	entry.Dir, _ = os.Getwd()
+
+	h.running = entry
+	h.add(entry)
+}
+
+// Finish records how the command started last went and appends it to the
+// file.
+func (h *History) Finish(status int) error {
+	entry := h.running
+	if entry == nil {
 		return nil
 	}
+	h.running = nil
 
-	return &History{
-		nextToWriteIndex: 1,
-		file:             file,
-		lines:            make([]string, 0),
+	entry.Duration = time.Since(entry.Start)
+	entry.Status = &status
+	h.unsaved = capLines(append(h.unsaved, entry), h.size)
+
+	err := h.AppendNew()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error writing history:", err)
 	}
+
+	return err
 }
 
-func (h *History) Run(args []string) {
-	if len(args) > 0 {
-		var cursor int64
+func (h *History) add(entries ...*HistoryEntry) {
+	h.entries = capLines(append(h.entries, entries...), h.size)
+	h.navigationIndex = len(h.entries)
+}
 
-		stat, _ := h.file.Stat()
-		filesize := stat.Size()
+// Lines returns the command lines in memory, oldest first.
+func (h *History) Lines() []string {
+	lines := make([]string, len(h.entries))
+	for i, entry := range h.entries {
+		lines[i] = entry.Line
+	}
+	return lines
+}
+
+// Clear forgets the history in memory. The file is left alone.
+func (h *History) Clear() {
+	h.entries = h.entries[:0]
+	h.unsaved = nil
+	h.navigationIndex = 0
+}
+
+// AppendNew appends the entries finished since the last save to the file.
+func (h *History) AppendNew() error {
+	if h.path == "" || len(h.unsaved) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return err
+	}
 
-		rowsAmount, err := strconv.Atoi(args[0])
+	return h.withFile(os.O_RDWR|os.O_CREATE|os.O_APPEND, syscall.LOCK_EX, func(file *os.File) error {
+		// Whatever other sessions appended meanwhile is read in later, if
+		// history is shared, and no more of it than fits in memory
+		skipped, err := h.readFrom(file)
 		if err != nil {
-			fmt.Println("Invalid number of rows:", err)
-			return
+			return err
 		}
+		h.pending = capLines(append(h.pending, skipped...), h.size)
 
-		var resp string
-		var linesFound int
-		for {
-			cursor -= 1
-			_, err := h.file.Seek(cursor, io.SeekEnd)
-			if err != nil {
-				fmt.Printf("Error seeking to #%v of file from end: %v", cursor, err)
-				return
-			}
+		content := encodeEntries(h.unsaved)
+		n, err := file.WriteString(content)
+		h.offset += int64(n)
+		if err != nil {
+			return err
+		}
 
-			char := make([]byte, 1)
-			h.file.Read(char)
+		h.fileLines += len(h.unsaved)
+		h.lastLine = lastLine(content)
+		h.unsaved = nil
 
-			if cursor != -1 && (char[0] == 10 || char[0] == 13) { // stop if we find a line
-				linesFound++
-				if linesFound == rowsAmount {
-					break
-				}
-			}
-			resp = fmt.Sprintf("%s%s", string(char), resp)
+		if h.fileSize >= 0 && h.fileLines > h.fileSize {
+			return h.truncate(file)
+		}
+		return nil
+	})
+}
 
-			if cursor == -filesize { // stop if we are at the begining
-				break
-			}
+// ReadAll appends every entry of the file to the history.
+func (h *History) ReadAll() error {
+	return h.withFile(os.O_RDONLY, syscall.LOCK_SH, func(file *os.File) error {
+		h.offset = 0
+		h.fileLines = 0
+		h.file = nil
+		h.lastLine = ""
+		h.pending = nil
+
+		entries, err := h.readFrom(file)
+		h.add(entries...)
+		return err
+	})
+}
+
+// ReadNew appends the entries other sessions added to the file since this
+// one last read it.
+func (h *History) ReadNew() error {
+	return h.withFile(os.O_RDONLY, syscall.LOCK_SH, func(file *os.File) error {
+		entries, err := h.readFrom(file)
+		h.add(append(h.pending, entries...)...)
+		h.pending = nil
+		return err
+	})
+}
+
+// WriteAll replaces the file with the history in memory. The command still
+// running is left to be appended when it finishes.
+func (h *History) WriteAll() error {
+	if h.path == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return err
+	}
+
+	entries := make([]*HistoryEntry, 0, len(h.entries))
+	for _, entry := range h.entries {
+		if entry != h.running {
+			entries = append(entries, entry)
 		}
+	}
+	entries = capLines(entries, h.fileSize)
+
+	return h.withFile(os.O_RDWR|os.O_CREATE, syscall.LOCK_EX, func(file *os.File) error {
+		h.unsaved = nil
+		h.pending = nil
+		return h.rewrite(encodeEntries(entries), len(entries))
+	})
+}
+
+// withFile runs fn on the history file while holding a lock of type how.
+// A missing file is fine for reading.
+func (h *History) withFile(flag int, how int, fn func(file *os.File) error) error {
+	if h.path == "" {
+		return nil
+	}
 
-		fmt.Print(resp)
-	} else {
-		// Seek to the beginning of the file to read from start
-		_, err := h.file.Seek(0, io.SeekStart)
+	for {
+		file, err := os.OpenFile(h.path, flag, 0600)
+		if os.IsNotExist(err) && flag == os.O_RDONLY {
+			return nil
+		}
 		if err != nil {
-			fmt.Println("Error seeking to start of file:", err)
-			return
+			return err
 		}
-		buf := make([]byte, 32*1024)
 
-		for {
-			n, err := h.file.Read(buf)
+		if err := syscall.Flock(int(file.Fd()), how); err != nil {
+			file.Close()
+			return err
+		}
 
-			if n > 0 {
-				fmt.Print(string(buf[:n]))
-			}
+		// The file was replaced while waiting for the lock, lock the new one
+		if !isCurrent(file, h.path) {
+			file.Close()
+			continue
+		}
 
-			if err == io.EOF {
-				break
-			}
+		err = fn(file)
+		file.Close()
+		return err
+	}
+}
 
-			if err != nil {
-				fmt.Println("Error reading file:", err)
-				return
-			}
+// isCurrent reports whether file is still the one at path.
+func isCurrent(file *os.File, path string) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(path)
+	return err == nil && os.SameFile(info, current)
+}
+
+// readFrom reads the entries on the complete lines past the offset this
+// session got to.
+func (h *History) readFrom(file *os.File) ([]*HistoryEntry, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// Another session trimmed the file, so the offset is into the old one
+	if h.file != nil && !os.SameFile(h.file, info) || info.Size() < h.offset {
+		if err := h.resync(file); err != nil {
+			return nil, err
 		}
+	}
+	h.file = info
+
+	if _, err := file.Seek(h.offset, io.SeekStart); err != nil {
+		return nil, err
+	}
 
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
 	}
+
+	end := strings.LastIndexByte(string(data), '\n') + 1
+	h.offset += int64(end)
+	if end == 0 {
+		return nil, nil
+	}
+
+	lines := strings.Split(string(data[:end-1]), "\n")
+	h.fileLines += len(lines)
+	h.lastLine = lines[len(lines)-1]
+
+	entries := make([]*HistoryEntry, len(lines))
+	for i, line := range lines {
+		entries[i] = decodeEntry(line)
+	}
+	return entries, nil
 }
 
-func (h *History) Write(input string) error {
-	message := fmt.Sprintf("    %v  %s", h.nextToWriteIndex, input)
-	h.lines = append(h.lines, strings.TrimSpace(input))
-	h.navigationIndex++
+// resync moves the offset past the last line this session read or wrote in
+// a file that was trimmed since. If that line was trimmed away too, all of
+// the file is new.
+func (h *History) resync(file *os.File) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
 
-	_, err := fmt.Fprintln(h.file, message)
+	data, err := io.ReadAll(file)
 	if err != nil {
-		fmt.Println("Error writing to file:", err)
+		return err
 	}
 
-	h.nextToWriteIndex++
+	h.offset = 0
+	h.fileLines = 0
+	if h.lastLine == "" {
+		return nil
+	}
 
+	content := "\n" + string(data)
+	if i := strings.LastIndex(content, "\n"+h.lastLine+"\n"); i != -1 {
+		h.offset = int64(i + len(h.lastLine) + 1)
+		h.fileLines = strings.Count(string(data[:h.offset]), "\n")
+	}
 	return nil
 }
 
-func (h *History) Close() error {
-	err := h.file.Close()
+// truncate drops the oldest lines of the file beyond HISTFILESIZE.
+func (h *History) truncate(file *os.File) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	lines := capLines(strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), h.fileSize)
+	return h.rewrite(strings.Join(lines, "\n")+"\n", len(lines))
+}
+
+// rewrite replaces the locked history file with a new one holding content,
+// which is count lines. Renaming it into place, rather than writing over
+// the old one, lets the other sessions see that their offsets are stale.
+func (h *History) rewrite(content string, count int) error {
+	temp, err := os.CreateTemp(filepath.Dir(h.path), ".history-*")
 	if err != nil {
-		fmt.Println("Error closing file:", err)
+		return err
 	}
+	defer os.Remove(temp.Name())
 
+	_, err = temp.WriteString(content)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(temp.Name(), h.path); err != nil {
+		return err
+	}
+
+	h.file, err = os.Stat(h.path)
+	if err != nil {
+		return err
+	}
+	h.offset = int64(len(content))
+	h.fileLines = count
+	h.lastLine = lastLine(content)
 	return nil
 }
 
+// lastLine is the last of the lines in content, which ends in a newline.
+func lastLine(content string) string {
+	content = strings.TrimSuffix(content, "\n")
+	return content[strings.LastIndexByte(content, '\n')+1:]
+}
+
+// capLines keeps the last size lines, or all of them for a negative size.
+func capLines[T any](lines []T, size int) []T {
+	if size < 0 || len(lines) <= size {
+		return lines
+	}
+	return lines[len(lines)-size:]
+}
+
+// encodeEntries formats entries as JSON lines.
+func encodeEntries(entries []*HistoryEntry) string {
+	var sb strings.Builder
+	encoder := json.NewEncoder(&sb)
+	// Keep redirections and && readable in the file
+	encoder.SetEscapeHTML(false)
+
+	for _, entry := range entries {
+		encoder.Encode(entry)
+	}
+	return sb.String()
+}
+
+// decodeEntry parses a line of the history file. Lines that are not JSON,
+// as written by older versions, are taken as a bare command line.
+func decodeEntry(line string) *HistoryEntry {
+	var entry HistoryEntry
+	if strings.HasPrefix(line, "{") && json.Unmarshal([]byte(line), &entry) == nil {
+		return &entry
+	}
+	return &HistoryEntry{Line: line}
+}
+
+// HistoryCmd is the history builtin. `history N` shows the last N entries,
+// -c clears the list, -a appends new entries to the file, -r reads the
+// whole file, -n reads the lines added to it since and -w overwrites it.
+// --failed lists only commands that failed and --dir DIR only those run
+// in DIR. Entries are shown with their start time when HISTTIMEFORMAT is
+// set, and --help describes it all. Each command is appended to the file
+// once it finishes; with `set -o sharehistory` the commands other sessions
+// ran are read in before each prompt too.
+type HistoryCmd struct {
+	repl *Repl
+}
+
+// historyUsage is what `history --help` shows.
+const historyUsage = `history: history [-c] [-a] [-r] [-n] [-w] [--failed] [--dir DIR] [N]
+    Display or manipulate the history list.
+
+    With N, list only the last N entries. Entries keep their numbers when
+    filtered, for !N.
+
+    Options:
+      -c         clear the history list
+      -a         append the commands run in this session to the history file
+      -r         read the history file and add it to the list
+      -n         read the lines added to the history file by other sessions
+      -w         write the whole list to the history file
+      --failed   list only the commands that failed
+      --dir DIR  list only the commands run in DIR
+
+    Each command is appended to the history file when it finishes, so other
+    sessions can read it with -n. After 'set -o sharehistory' every session
+    does so before each prompt; 'set +o sharehistory' turns this off.
+
+    HISTFILE names the history file, HISTSIZE and HISTFILESIZE cap the
+    list and the file, and HISTTIMEFORMAT shows when each command started.
+`
+
+func (c *HistoryCmd) Run(args []string) int {
+	history := c.repl.History
+
+	if len(args) > 0 && strings.HasPrefix(args[0], "-") && len(args[0]) > 1 && !strings.HasPrefix(args[0], "--") {
+		for _, flag := range args[0][1:] {
+			var err error
+			switch flag {
+			case 'c':
+				history.Clear()
+			case 'a':
+				err = history.AppendNew()
+			case 'r':
+				err = history.ReadAll()
+			case 'n':
+				err = history.ReadNew()
+			case 'w':
+				err = history.WriteAll()
+			default:
+				c.repl.PrintError(fmt.Sprintf("history: -%c: invalid option", flag))
+				return 2
+			}
+			if err != nil {
+				c.repl.PrintError(fmt.Sprintf("history: %v", err))
+				return 1
+			}
+		}
+		return 0
+	}
+
+	var failed bool
+	var dir string
+	count := -1
+
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--help":
+			c.repl.Print(historyUsage)
+			return 0
+		case arg == "--failed":
+			failed = true
+		case arg == "--dir":
+			if i+1 == len(args) {
+				c.repl.PrintError("history: --dir: option requires an argument")
+				return 2
+			}
+			i++
+			path, err := filepath.Abs(args[i])
+			if err != nil {
+				c.repl.PrintError(fmt.Sprintf("history: %v", err))
+				return 1
+			}
+			dir = path
+		case strings.HasPrefix(arg, "-"):
+			c.repl.PrintError(fmt.Sprintf("history: %s: invalid option", arg))
+			return 2
+		case count == -1:
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 0 {
+				c.repl.PrintError(fmt.Sprintf("history: %s: numeric argument required", arg))
+				return 2
+			}
+			count = n
+		default:
+			c.repl.PrintError("history: too many arguments")
+			return 2
+		}
+	}
+
+	// Entries keep their numbers when filtered, for !n
+	var shown []int
+	for i, entry := range history.entries {
+		if failed && !entry.Failed() || dir != "" && entry.Dir != dir {
+			continue
+		}
+		shown = append(shown, i)
+	}
+	if count >= 0 {
+		shown = capLines(shown, count)
+	}
+
+	timeFormat, showTime := os.LookupEnv("HISTTIMEFORMAT")
+
+	var sb strings.Builder
+	for _, i := range shown {
+		entry := history.entries[i]
+		fmt.Fprintf(&sb, "%5d  ", i+1)
+		if showTime && !entry.Start.IsZero() {
+			sb.WriteString(formatTime(entry.Start, timeFormat))
+		}
+		sb.WriteString(entry.Line)
+		sb.WriteString("\n")
+	}
+	c.repl.Print(sb.String())
+
+	return 0
+}
+
 func (h *History) Down() string {
-	if h.navigationIndex == len(h.lines)-1 {
+	if h.navigationIndex >= len(h.entries)-1 {
+		h.navigationIndex = len(h.entries)
 		return ""
 	}
 
 	h.navigationIndex++
-	line := h.lines[h.navigationIndex]
-	return line
+	return h.entries[h.navigationIndex].Line
 }
 
 func (h *History) Up() string {
-	if len(h.lines) == 0 || h.navigationIndex == 0 {
+	if len(h.entries) == 0 || h.navigationIndex == 0 {
 		return ""
 	}
 
 	h.navigationIndex--
-	line := h.lines[h.navigationIndex]
-	return line
+	return h.entries[h.navigationIndex].Line
 }
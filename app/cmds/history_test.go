@@ -0,0 +1,89 @@
+package cmds
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// run enters and finishes each line in h.
+func run(t *testing.T, h *History, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		h.Start(line)
+		if err := h.Finish(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSharedHistoryTrimmed(t *testing.T) {
+	t.Setenv("HISTFILE", filepath.Join(t.TempDir(), "history"))
+	t.Setenv("HISTFILESIZE", "5")
+
+	a, b := InitHistory(), InitHistory()
+	for _, h := range []*History{a, b} {
+		if err := h.Load(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run(t, a, "a1", "a2", "a3")
+	if err := b.ReadNew(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a trims the file down to a2 to a6 after b read up to a3
+	run(t, a, "a4", "a5", "a6")
+	if err := b.ReadNew(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a1", "a2", "a3", "a4", "a5", "a6"}
+	if got := b.Lines(); !slices.Equal(got, want) {
+		t.Errorf("b = %q, want %q", got, want)
+	}
+
+	// b trims it again, with a7 to read that it skipped over
+	run(t, a, "a7")
+	run(t, b, "b1")
+	if err := a.ReadNew(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.ReadNew(); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"a1", "a2", "a3", "a4", "a5", "a6", "a7", "b1"}
+	if got := a.Lines(); !slices.Equal(got, want) {
+		t.Errorf("a = %q, want %q", got, want)
+	}
+	want = []string{"a1", "a2", "a3", "a4", "a5", "a6", "b1", "a7"}
+	if got := b.Lines(); !slices.Equal(got, want) {
+		t.Errorf("b = %q, want %q", got, want)
+	}
+
+	// A new session sees the file as trimmed
+	c := InitHistory()
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"a4", "a5", "a6", "a7", "b1"}
+	if got := c.Lines(); !slices.Equal(got, want) {
+		t.Errorf("c = %q, want %q", got, want)
+	}
+}
+
+func TestSkippedEntriesCapped(t *testing.T) {
+	t.Setenv("HISTFILE", filepath.Join(t.TempDir(), "history"))
+	t.Setenv("HISTSIZE", "3")
+
+	a, b := InitHistory(), InitHistory()
+	for i := range 10 {
+		run(t, a, fmt.Sprintf("a%d", i))
+		run(t, b, fmt.Sprintf("b%d", i))
+	}
+
+	if len(b.pending) > 3 {
+		t.Errorf("b has %d entries pending, want at most 3", len(b.pending))
+	}
+}
@@ -0,0 +1,142 @@
+package cmds
+
+import (
+	"fmt"
+	"syscall"
+
+	"github.com/codecrafters-io/shell-starter-go/app/internal/jobs"
+)
+
+// ListJobs is the jobs builtin.
+type ListJobs struct {
+	repl *Repl
+}
+
+func (l *ListJobs) Run(args []string) int {
+	list := l.repl.Jobs.List()
+
+	if len(args) > 0 {
+		list = list[:0]
+		for _, spec := range args {
+			job, err := l.repl.Jobs.Find(spec)
+			if err != nil {
+				l.repl.PrintError(fmt.Sprintf("jobs: %v", err))
+				return 1
+			}
+			list = append(list, job)
+		}
+	}
+
+	for _, job := range list {
+		l.repl.Print(l.repl.Jobs.Format(job) + "\n")
+	}
+
+	return 0
+}
+
+// Fg is the fg builtin: it resumes a job in the foreground and waits for it.
+type Fg struct {
+	repl *Repl
+}
+
+func (f *Fg) Run(args []string) int {
+	job, ok := findJob(f.repl, "fg", args)
+	if !ok {
+		return 1
+	}
+
+	f.repl.Print(job.Command + "\n")
+
+	if err := job.Continue(); err != nil {
+		f.repl.PrintError(fmt.Sprintf("fg: %v", err))
+		return 1
+	}
+
+	if f.repl.Jobs.Foreground(job) == jobs.Stopped {
+		f.repl.PrintError("\n" + f.repl.Jobs.Report(job))
+		return 128 + int(syscall.SIGTSTP)
+	}
+
+	f.repl.Jobs.Remove(job)
+	return job.Status()
+}
+
+// Bg is the bg builtin: it resumes a stopped job in the background.
+type Bg struct {
+	repl *Repl
+}
+
+func (b *Bg) Run(args []string) int {
+	job, ok := findJob(b.repl, "bg", args)
+	if !ok {
+		return 1
+	}
+
+	if job.State() == jobs.Running {
+		b.repl.PrintError(fmt.Sprintf("bg: job %d already in background", job.ID))
+		return 0
+	}
+
+	if err := job.Continue(); err != nil {
+		b.repl.PrintError(fmt.Sprintf("bg: %v", err))
+		return 1
+	}
+
+	b.repl.Print(b.repl.Jobs.Format(job) + "\n")
+	return 0
+}
+
+// Wait is the wait builtin. Without arguments it waits for every running
+// job and succeeds; otherwise it returns the status of the last job given.
+// Ctrl-C stops the waiting with status 130.
+type Wait struct {
+	repl *Repl
+}
+
+func (w *Wait) Run(args []string) int {
+	if len(args) == 0 {
+		for _, job := range w.repl.Jobs.List() {
+			if job.State() == jobs.Stopped {
+				continue
+			}
+			if _, ok := w.repl.Jobs.Wait(job); !ok {
+				return 128 + int(syscall.SIGINT)
+			}
+			w.repl.Jobs.Remove(job)
+		}
+		return 0
+	}
+
+	status := 0
+	for _, spec := range args {
+		job, err := w.repl.Jobs.Find(spec)
+		if err != nil {
+			w.repl.PrintError(fmt.Sprintf("wait: %v", err))
+			status = 127
+			continue
+		}
+		var ok bool
+		status, ok = w.repl.Jobs.Wait(job)
+		if !ok {
+			return 128 + int(syscall.SIGINT)
+		}
+		w.repl.Jobs.Remove(job)
+	}
+
+	return status
+}
+
+func findJob(repl *Repl, name string, args []string) (*jobs.Job, bool) {
+	spec := ""
+	if len(args) > 0 {
+		spec = args[0]
+	}
+
+	job, err := repl.Jobs.Find(spec)
+	if err != nil {
+		repl.PrintError(fmt.Sprintf("%s: %v", name, err))
+		return nil, false
+	}
+
+	return job, true
+}
@@ -0,0 +1,110 @@
+package cmds
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Options are the shell options that `set -o` and `set +o` turn on and off.
+type Options struct {
+	// Vi edits command lines with vi keys rather than emacs ones
+	Vi bool
+	// ShareHistory reads the commands other sessions ran before each prompt
+	ShareHistory bool
+}
+
+// optionNames lists the options in the order `set -o` shows them.
+var optionNames = []string{"emacs", "sharehistory", "vi"}
+
+func (o *Options) get(name string) bool {
+	switch name {
+	case "emacs":
+		return !o.Vi
+	case "sharehistory":
+		return o.ShareHistory
+	}
+	return o.Vi
+}
+
+// set turns an option on or off and reports false for an unknown name.
+// emacs and vi pick the editing keys, so one of them is always on.
+func (o *Options) set(name string, on bool) bool {
+	switch name {
+	case "emacs":
+		o.Vi = !on
+	case "vi":
+		o.Vi = on
+	case "sharehistory":
+		o.ShareHistory = on
+	default:
+		return false
+	}
+	return true
+}
+
+// Options returns the shell's options, for the line editor to follow.
+func (r *Repl) Options() *Options {
+	return &r.options
+}
+
+// Set is the set builtin. `set -o NAME` turns an option on and `set +o NAME`
+// turns it off; without a name they list the options. Other arguments,
+// after an optional --, become the positional parameters.
+type Set struct {
+	repl *Repl
+}
+
+func (s *Set) Run(args []string) int {
+	options := s.repl.Options()
+
+	for len(args) > 0 {
+		arg := args[0]
+
+		switch {
+		case arg == "--":
+			s.repl.SetPositional(s.repl.positional[0], args[1:])
+			return 0
+		case arg == "-o" || arg == "+o":
+			on := arg == "-o"
+			if len(args) == 1 {
+				s.printOptions(on)
+				return 0
+			}
+			if !options.set(args[1], on) {
+				s.repl.PrintError(fmt.Sprintf("set: %s: invalid option name", args[1]))
+				return 2
+			}
+			args = args[2:]
+		case strings.HasPrefix(arg, "-") || strings.HasPrefix(arg, "+"):
+			s.repl.PrintError(fmt.Sprintf("set: %s: invalid option", arg))
+			return 2
+		default:
+			s.repl.SetPositional(s.repl.positional[0], args)
+			return 0
+		}
+	}
+
+	return 0
+}
+
+// printOptions lists the options as `set -o` does, or as the commands that
+// would restore them for `set +o`.
+func (s *Set) printOptions(table bool) {
+	options := s.repl.Options()
+
+	var sb strings.Builder
+	for _, name := range optionNames {
+		on := options.get(name)
+		switch {
+		case table && on:
+			fmt.Fprintf(&sb, "%-15s\ton\n", name)
+		case table:
+			fmt.Fprintf(&sb, "%-15s\toff\n", name)
+		case on:
+			fmt.Fprintf(&sb, "set -o %s\n", name)
+		default:
+			fmt.Fprintf(&sb, "set +o %s\n", name)
+		}
+	}
+	s.repl.Print(sb.String())
+}
@@ -5,7 +5,7 @@ import (
 	"slices"
 )
 
-var AvailableCmds = []string{"exit", "type", "echo", "pwd", "cd", "history"}
+var AvailableCmds = []string{"exit", "type", "echo", "pwd", "cd", "history", "jobs", "fg", "bg", "wait", "source", ".", "set", "bind", "export"}
 
 type Type struct {
 	repl          *Repl
@@ -19,25 +19,26 @@ func InitType(repl *Repl) *Type {
 	}
 }
 
-func (t *Type) Run(args []string) {
+func (t *Type) Run(args []string) int {
 	if len(args) == 0 {
 		t.repl.PrintError("type: missing operand")
-		return
+		return 2
 	}
 
 	searchableBin := args[0]
 	has := slices.Contains(t.availableCmds, searchableBin)
 	if has {
 		t.repl.Print(fmt.Sprintf("%v is a shell builtin\n", searchableBin))
-		return
+		return 0
 	}
 
 	path, ok := t.repl.CmdExist(searchableBin)
 
 	if !ok {
 		t.repl.Print(fmt.Sprintf("%v: not found\n", searchableBin))
-		return
+		return 1
 	}
 
 	t.repl.Print(fmt.Sprintf("%s is %s\n", searchableBin, path))
+	return 0
 }
@@ -0,0 +1,156 @@
+package cmds
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/app/internal/expand"
+)
+
+// SetVar assigns value to the variable name. An exported variable is set in
+// the environment, which the commands the shell runs inherit.
+func (r *Repl) SetVar(name, value string) {
+	if _, inEnv := os.LookupEnv(name); inEnv || r.exported[name] {
+		os.Setenv(name, value)
+		delete(r.vars, name)
+		delete(r.exported, name)
+	} else {
+		if r.vars == nil {
+			r.vars = make(map[string]string)
+		}
+		r.vars[name] = value
+	}
+
+	if name == "PATH" {
+		r.loadCommands()
+	}
+}
+
+// ExportVar moves the variable name to the environment. A name not set yet
+// is exported once it is.
+func (r *Repl) ExportVar(name string) {
+	value, ok := r.vars[name]
+	if !ok {
+		if _, inEnv := os.LookupEnv(name); !inEnv {
+			if r.exported == nil {
+				r.exported = make(map[string]bool)
+			}
+			r.exported[name] = true
+		}
+		return
+	}
+
+	delete(r.vars, name)
+	os.Setenv(name, value)
+
+	if name == "PATH" {
+		r.loadCommands()
+	}
+}
+
+// ShadowVars gives the variables in assignments, NAME=value each, to a
+// builtin about to run, until restore is called. The environment is left
+// alone, so nothing else the shell runs sees them.
+func (r *Repl) ShadowVars(assignments []string) (restore func()) {
+	if r.vars == nil {
+		r.vars = make(map[string]string)
+	}
+
+	saved := make(map[string]*string, len(assignments))
+	for _, assignment := range assignments {
+		name, value, _ := strings.Cut(assignment, "=")
+		if _, done := saved[name]; !done {
+			saved[name] = nil
+			if old, ok := r.vars[name]; ok {
+				saved[name] = &old
+			}
+		}
+		r.vars[name] = value
+	}
+
+	return func() {
+		for name, old := range saved {
+			if old != nil {
+				r.vars[name] = *old
+			} else {
+				delete(r.vars, name)
+			}
+		}
+	}
+}
+
+// Declarations returns the commands that recreate in another shell the
+// variables the environment does not pass on: the ones not exported, and
+// the names exported before they were set.
+func (r *Repl) Declarations() string {
+	var sb strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(r.vars)) {
+		fmt.Fprintf(&sb, "%s=%s\n", name, expand.Quote(r.vars[name]))
+	}
+	for _, name := range slices.Sorted(maps.Keys(r.exported)) {
+		fmt.Fprintf(&sb, "export %s\n", name)
+	}
+	return sb.String()
+}
+
+// Copy returns a copy of the repl with variables of its own, for a command
+// that runs apart from the shell. Redirections and $? can change on the
+// copy without touching the original.
+func (r *Repl) Copy() *Repl {
+	newRepl := &Repl{}
+	*newRepl = *r
+	newRepl.vars = maps.Clone(r.vars)
+	newRepl.exported = maps.Clone(r.exported)
+	return newRepl
+}
+
+// Export is the export builtin. `export NAME=value` sets and exports a
+// variable and `export NAME` exports one; without names, or with -p, it
+// lists the exported variables as the commands that would export them.
+type Export struct {
+	repl *Repl
+}
+
+func (e *Export) Run(args []string) int {
+	if len(args) > 0 && args[0] == "-p" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		e.printExported()
+		return 0
+	}
+
+	status := 0
+	for _, arg := range args {
+		name, value, assign := strings.Cut(arg, "=")
+		if !expand.IsName(name) {
+			e.repl.PrintError(fmt.Sprintf("export: `%s': not a valid identifier", arg))
+			status = 1
+			continue
+		}
+
+		if assign {
+			e.repl.SetVar(name, value)
+		}
+		e.repl.ExportVar(name)
+	}
+
+	return status
+}
+
+func (e *Export) printExported() {
+	lines := make([]string, 0)
+	for _, entry := range os.Environ() {
+		name, value, _ := strings.Cut(entry, "=")
+		lines = append(lines, fmt.Sprintf("export %s=%s\n", name, expand.Quote(value)))
+	}
+	for name := range e.repl.exported {
+		lines = append(lines, fmt.Sprintf("export %s\n", name))
+	}
+	slices.Sort(lines)
+
+	e.repl.Print(strings.Join(lines, ""))
+}
@@ -1,93 +1,77 @@
 package args
 
 import (
-	"bufio"
-	"os"
-	"slices"
+	"fmt"
 	"strings"
 )
 
-var preservedSymbols = []byte{'"', '$', '\\'}
-
-func ParseArgs() (string, []string) {
-	args := make([]string, 0)
-	input, _ := bufio.NewReader(os.Stdin).ReadString('\n')
-
-	inQuotes := false
-	inDoubleQuotes := false
-	preserveBackslash := false
-
-	var currentArg strings.Builder
-
-	// Split the input into args, handling quotes properly
-	for i := 0; i < len(input); i++ {
-		ch := input[i]
-
-		if slices.Contains(preservedSymbols, ch) && preserveBackslash {
-			currentArg.WriteByte(input[i])
-			preserveBackslash = false
-			continue
-		}
-
-		if ch == '\\' {
-			if inQuotes {
-				currentArg.WriteByte(input[i])
-				i++
-				if i < len(input) {
-					currentArg.WriteByte(input[i])
-				}
-				continue
-			}
-
-			if inDoubleQuotes && !preserveBackslash {
-				preserveBackslash = true
-				continue
-			}
-		}
-
-		if ch == '"' && !inQuotes {
-			inDoubleQuotes = !inDoubleQuotes
-			continue
-		}
-
-		if ch == '\'' && !inDoubleQuotes {
-			inQuotes = !inQuotes
-			continue
-		}
+// Options is what the shell was asked to run on its command line.
+type Options struct {
+	// Command is the string given with -c, nil without it
+	Command *string
+	// Script is the file to run, empty when reading stdin
+	Script string
+	// Name is $0 and Params are $1 onwards
+	Name   string
+	Params []string
+	// NoRC skips the rc file of an interactive shell
+	NoRC bool
+}
 
-		if ch == '\\' && !inQuotes && !inDoubleQuotes {
-			i++
-			if i < len(input) {
-				currentArg.WriteByte(input[i])
-			}
-			continue
-		}
+// ReadsStdin reports whether the shell takes its commands from stdin, where
+// a terminal makes it interactive.
+func (o *Options) ReadsStdin() bool {
+	return o.Command == nil && o.Script == ""
+}
 
-		if !inQuotes && !inDoubleQuotes && ch == ' ' {
-			// If we have accumulated characters and hit a space outside quotes
-			if currentArg.Len() > 0 {
-				args = append(args, currentArg.String())
-				currentArg.Reset()
+// ParseArgs parses the shell's own arguments, argv[0] included:
+//
+//	shell [--norc] [-c command [name [arg...]]]
+//	shell [--norc] [script [arg...]]
+func ParseArgs(argv []string) (*Options, error) {
+	opts := &Options{Name: argv[0]}
+	rest := argv[1:]
+
+	for len(rest) > 0 && strings.HasPrefix(rest[0], "-") && rest[0] != "-" {
+		arg := rest[0]
+		rest = rest[1:]
+
+		switch arg {
+		case "--":
+			return opts.operands(rest), nil
+		case "--norc":
+			opts.NoRC = true
+		case "-c":
+			if len(rest) == 0 {
+				return nil, fmt.Errorf("-c: option requires an argument")
 			}
-			continue
+			opts.Command = &rest[0]
+			rest = rest[1:]
+		default:
+			return nil, fmt.Errorf("%s: invalid option", arg)
 		}
+	}
 
-		if preserveBackslash {
-			currentArg.WriteByte('\\')
-			preserveBackslash = false
-		}
+	return opts.operands(rest), nil
+}
 
-		currentArg.WriteByte(ch)
+// operands takes what follows the options: with -c the name and arguments,
+// otherwise the script and its arguments.
+func (o *Options) operands(rest []string) *Options {
+	// A lone - stands for stdin
+	if o.Command == nil && len(rest) > 0 && rest[0] == "-" {
+		rest = rest[1:]
 	}
 
-	// Add the last argument if there is one
-	if currentArg.Len() > 0 {
-		trimmedArg := strings.TrimRight(currentArg.String(), "\n")
-		args = append(args, trimmedArg)
+	if len(rest) == 0 {
+		return o
 	}
 
-	command := args[0]
-	cmdArgs := args[1:]
+	if o.Command == nil {
+		o.Script = rest[0]
+	}
+	o.Name = rest[0]
+	o.Params = rest[1:]
 
-	return command, cmdArgs
+	return o
 }
@@ -0,0 +1,323 @@
+package expand
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Lookup resolves a parameter name to its value. A nil Lookup disables
+// parameter expansion and leaves only quote removal.
+type Lookup func(name string) (string, bool)
+
+const ifs = " \t\n"
+
+var ErrBadSubstitution = fmt.Errorf("bad substitution")
+
+type expander struct {
+	lookup Lookup
+	fields []string
+	cur    strings.Builder
+	// hasCur is set once the current field exists, even if it is empty ("" or '')
+	hasCur bool
+	// noSplit keeps unquoted expansions whole, as in an assignment
+	noSplit bool
+	// noParams is set while the double quotes being read hold nothing but
+	// a $@ that expanded to no parameters
+	noParams bool
+}
+
+// Word performs quote removal and parameter expansion on a single raw word
+// and returns the resulting fields. Unquoted expansions are split on blanks,
+// expansions inside double quotes are kept intact and single quotes disable
+// expansion entirely.
+func Word(raw string, lookup Lookup) ([]string, error) {
+	return (&expander{lookup: lookup}).word(raw)
+}
+
+// Value expands the value of an assignment, which is a single word whatever
+// it expands to: nothing is split on blanks.
+func Value(raw string, lookup Lookup) (string, error) {
+	fields, err := (&expander{lookup: lookup, noSplit: true}).word(raw)
+	return strings.Join(fields, " "), err
+}
+
+// IsAssignment reports whether word assigns a variable, like NAME=value.
+func IsAssignment(word string) bool {
+	name, _, ok := strings.Cut(word, "=")
+	return ok && IsName(name)
+}
+
+// Quote quotes s so that Word expands it back to s as a single field.
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func (e *expander) word(raw string) ([]string, error) {
+	inQuotes := false
+	inDoubleQuotes := false
+
+	for i := 0; i < len(raw); i++ {
+		ch := raw[i]
+
+		if inQuotes {
+			if ch == '\'' {
+				inQuotes = false
+				continue
+			}
+			e.writeByte(ch)
+			continue
+		}
+
+		switch ch {
+		case '\'':
+			if inDoubleQuotes {
+				e.writeByte(ch)
+				continue
+			}
+			inQuotes = true
+			e.hasCur = true
+		case '"':
+			inDoubleQuotes = !inDoubleQuotes
+			if inDoubleQuotes {
+				e.noParams = false
+			} else if !e.noParams {
+				// Quotes make a field even when empty, except for a
+				// lone "$@" without positional parameters
+				e.hasCur = true
+			}
+		case '\\':
+			if i+1 >= len(raw) {
+				e.writeByte(ch)
+				continue
+			}
+			next := raw[i+1]
+			if next == '\n' {
+				// A line continuation disappears entirely
+				i++
+				continue
+			}
+			if inDoubleQuotes && !strings.ContainsRune(`"$\`, rune(next)) {
+				e.writeByte(ch)
+				continue
+			}
+			e.writeByte(next)
+			i++
+		case '$':
+			if inDoubleQuotes && e.lookup != nil {
+				if consumed := atLength(raw[i+1:]); consumed > 0 {
+					e.positional()
+					i += consumed
+					continue
+				}
+			}
+			value, consumed, err := e.parameter(raw[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			if consumed == 0 {
+				e.writeByte(ch)
+				continue
+			}
+			i += consumed
+			if inDoubleQuotes || e.noSplit {
+				e.hasCur = true
+				e.cur.WriteString(value)
+			} else {
+				e.split(value)
+			}
+		default:
+			e.writeByte(ch)
+		}
+	}
+
+	e.flush()
+
+	return e.fields, nil
+}
+
+// HereDoc expands parameters in a here-document body. Quotes have no special
+// meaning there and a backslash only escapes '$', '\\' and a newline.
+func HereDoc(body string, lookup Lookup) (string, error) {
+	e := &expander{lookup: lookup}
+
+	for i := 0; i < len(body); i++ {
+		ch := body[i]
+
+		switch ch {
+		case '\\':
+			if i+1 < len(body) && strings.ContainsRune("$\\\n", rune(body[i+1])) {
+				i++
+				if body[i] != '\n' {
+					e.cur.WriteByte(body[i])
+				}
+				continue
+			}
+			e.cur.WriteByte(ch)
+		case '$':
+			value, consumed, err := e.parameter(body[i+1:])
+			if err != nil {
+				return "", err
+			}
+			if consumed == 0 {
+				e.cur.WriteByte(ch)
+				continue
+			}
+			i += consumed
+			e.cur.WriteString(value)
+		default:
+			e.cur.WriteByte(ch)
+		}
+	}
+
+	return e.cur.String(), nil
+}
+
+// parameter expands the parameter reference following a '$' and reports
+// how many bytes of input it consumed. Zero means '$' is a literal.
+func (e *expander) parameter(input string) (string, int, error) {
+	if len(input) == 0 || e.lookup == nil {
+		return "", 0, nil
+	}
+
+	if input[0] == '{' {
+		end := strings.IndexByte(input, '}')
+		if end == -1 {
+			return "", 0, ErrBadSubstitution
+		}
+		name := input[1:end]
+		if !IsName(name) && !isNumber(name) && !(len(name) == 1 && isSpecial(name[0])) {
+			return "", 0, fmt.Errorf("${%s}: %w", name, ErrBadSubstitution)
+		}
+		value, _ := e.lookup(name)
+		return value, end + 1, nil
+	}
+
+	if isSpecial(input[0]) {
+		value, _ := e.lookup(input[:1])
+		return value, 1, nil
+	}
+
+	n := nameLength(input)
+	if n == 0 {
+		return "", 0, nil
+	}
+
+	value, _ := e.lookup(input[:n])
+	return value, n, nil
+}
+
+// positional expands a quoted $@ to one field per positional parameter.
+// The first joins the text before it and the last the text after it, and
+// with no parameters nothing is added at all.
+func (e *expander) positional() {
+	count, _ := e.lookup("#")
+	n, err := strconv.Atoi(count)
+	if err != nil {
+		value, _ := e.lookup("@")
+		e.hasCur = true
+		e.cur.WriteString(value)
+		return
+	}
+
+	if n == 0 && !e.hasCur {
+		e.noParams = true
+		return
+	}
+
+	for i := 1; i <= n; i++ {
+		if i > 1 {
+			e.flush()
+		}
+		value, _ := e.lookup(strconv.Itoa(i))
+		e.hasCur = true
+		e.cur.WriteString(value)
+	}
+}
+
+// split appends an unquoted expansion, breaking it into fields on blanks.
+func (e *expander) split(value string) {
+	for len(value) > 0 {
+		idx := strings.IndexAny(value, ifs)
+		if idx == -1 {
+			e.hasCur = true
+			e.cur.WriteString(value)
+			return
+		}
+
+		if idx > 0 {
+			e.hasCur = true
+			e.cur.WriteString(value[:idx])
+		}
+		e.flush()
+		value = strings.TrimLeft(value[idx:], ifs)
+	}
+}
+
+func (e *expander) writeByte(ch byte) {
+	e.hasCur = true
+	e.cur.WriteByte(ch)
+}
+
+func (e *expander) flush() {
+	if !e.hasCur {
+		return
+	}
+	e.fields = append(e.fields, e.cur.String())
+	e.cur.Reset()
+	e.hasCur = false
+}
+
+// atLength reports how many bytes of input, following a '$', refer to $@.
+// Zero means input names some other parameter.
+func atLength(input string) int {
+	switch {
+	case strings.HasPrefix(input, "@"):
+		return 1
+	case strings.HasPrefix(input, "{@}"):
+		return 3
+	}
+	return 0
+}
+
+func nameLength(input string) int {
+	for i := 0; i < len(input); i++ {
+		ch := input[i]
+		if ch == '_' || isLetter(ch) || (i > 0 && isDigit(ch)) {
+			continue
+		}
+		return i
+	}
+	return len(input)
+}
+
+// IsName reports whether name can name a variable.
+func IsName(name string) bool {
+	return name != "" && nameLength(name) == len(name)
+}
+
+// isSpecial reports whether ch names a single-character special parameter.
+// A digit names a positional parameter; more digits need braces.
+func isSpecial(ch byte) bool {
+	return strings.IndexByte("?#@*", ch) != -1 || isDigit(ch)
+}
+
+func isNumber(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isDigit(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isLetter(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
@@ -0,0 +1,244 @@
+package expand
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+var vars = map[string]string{
+	"HOME":   "/home/u",
+	"SPACED": "a  b\tc ",
+	"EMPTY":  "",
+	"?":      "0",
+	"0":      "gosh",
+	"1":      "one",
+	"10":     "ten",
+	"2":      "b  c",
+	"#":      "2",
+	"@":      "one b  c",
+	"*":      "one b  c",
+}
+
+func lookup(name string) (string, bool) {
+	value, ok := vars[name]
+	return value, ok
+}
+
+func TestWord(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{"plain", []string{"plain"}},
+		{"$HOME", []string{"/home/u"}},
+		{"${HOME}/x", []string{"/home/u/x"}},
+		{"$HOME.d", []string{"/home/u.d"}},
+		{"$? $0 $1", []string{"0 gosh one"}},
+		{"$10", []string{"one0"}},
+		{"${10}", []string{"ten"}},
+
+		// Unquoted expansions are split on blanks, quoted ones are not
+		{"$SPACED", []string{"a", "b", "c"}},
+		{"x${SPACED}y", []string{"xa", "b", "c", "y"}},
+		{`"$SPACED"`, []string{"a  b\tc "}},
+		{`"x $HOME"'$HOME'`, []string{"x /home/u$HOME"}},
+
+		// Empty expansions disappear unless quoted
+		{"$EMPTY", nil},
+		{"$UNSET", nil},
+		{`"$EMPTY"`, []string{""}},
+		{"''", []string{""}},
+		{`""$EMPTY`, []string{""}},
+
+		// Quote removal and escapes
+		{"'$HOME'", []string{"$HOME"}},
+		{`"'$HOME'"`, []string{"'/home/u'"}},
+		{`\$HOME`, []string{"$HOME"}},
+		{`a\ b`, []string{"a b"}},
+		{`"a\"b"`, []string{`a"b`}},
+		{`"a\$b"`, []string{"a$b"}},
+		{`"a\\b"`, []string{`a\b`}},
+		{`"a\nb"`, []string{`a\nb`}},
+		{`'a\nb'`, []string{`a\nb`}},
+		{"a\\\nb", []string{"ab"}},
+
+		// A quoted $@ gives one field per positional parameter
+		{"$@", []string{"one", "b", "c"}},
+		{`"$@"`, []string{"one", "b  c"}},
+		{`"${@}"`, []string{"one", "b  c"}},
+		{`"<$@>"`, []string{"<one", "b  c>"}},
+		{`"$*"`, []string{"one b  c"}},
+
+		// A $ that starts no parameter is literal
+		{"a$", []string{"a$"}},
+		{"$-x", []string{"$-x"}},
+		{`"$"`, []string{"$"}},
+	}
+
+	for _, test := range tests {
+		got, err := Word(test.raw, lookup)
+		if err != nil {
+			t.Errorf("Word(%q): %v", test.raw, err)
+			continue
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("Word(%q) = %q, want %q", test.raw, got, test.want)
+		}
+	}
+}
+
+func TestWordNoPositional(t *testing.T) {
+	none := func(name string) (string, bool) {
+		if name == "#" {
+			return "0", true
+		}
+		return "", false
+	}
+
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{`"$@"`, nil},
+		{`"$@"x`, []string{"x"}},
+		{`"""$@"`, []string{""}},
+		{`"$*"`, []string{""}},
+	}
+
+	for _, test := range tests {
+		got, err := Word(test.raw, none)
+		if err != nil {
+			t.Errorf("Word(%q): %v", test.raw, err)
+			continue
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("Word(%q) = %q, want %q", test.raw, got, test.want)
+		}
+	}
+}
+
+func TestWordWithoutLookup(t *testing.T) {
+	tests := map[string]string{
+		"$HOME":       "$HOME",
+		`"a b"`:       "a b",
+		`'x'"y"\z`:    "xyz",
+		`"${HOME"`:    "${HOME",
+		`EOF`:         "EOF",
+		`"E"'O'\F`:    "EOF",
+		`"$EMPTY"`:    "$EMPTY",
+		`\$\{HOME\}`:  "${HOME}",
+		`a\"b`:        `a"b`,
+		`"it's"`:      "it's",
+		`'say "hi"'`:  `say "hi"`,
+		`"tab\	here"`: `tab\	here`,
+	}
+
+	for raw, want := range tests {
+		got, err := Word(raw, nil)
+		if err != nil {
+			t.Errorf("Word(%q, nil): %v", raw, err)
+			continue
+		}
+		if len(got) != 1 || got[0] != want {
+			t.Errorf("Word(%q, nil) = %q, want [%q]", raw, got, want)
+		}
+	}
+}
+
+func TestValue(t *testing.T) {
+	tests := map[string]string{
+		"":           "",
+		"$SPACED":    "a  b\tc ",
+		"x${SPACED}": "xa  b\tc ",
+		"'a b'":      "a b",
+		"$UNSET":     "",
+		`"$@"`:       "one b  c",
+	}
+
+	for raw, want := range tests {
+		got, err := Value(raw, lookup)
+		if err != nil {
+			t.Errorf("Value(%q): %v", raw, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("Value(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestIsAssignment(t *testing.T) {
+	tests := map[string]bool{
+		"X=1":    true,
+		"_x2=":   true,
+		"X=a=b":  true,
+		"X":      false,
+		"=1":     false,
+		"1X=1":   false,
+		"'X'=1":  false,
+		"a-b=1":  false,
+		"--op=1": false,
+	}
+
+	for word, want := range tests {
+		if got := IsAssignment(word); got != want {
+			t.Errorf("IsAssignment(%q) = %v, want %v", word, got, want)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	for _, s := range []string{"", "plain", "a b", "it's", `"$HOME"`, "\\n", "''"} {
+		got, err := Word(Quote(s), lookup)
+		if err != nil {
+			t.Errorf("Word(Quote(%q)): %v", s, err)
+			continue
+		}
+		if len(got) != 1 || got[0] != s {
+			t.Errorf("Word(Quote(%q)) = %q", s, got)
+		}
+	}
+}
+
+func TestBadSubstitution(t *testing.T) {
+	for _, raw := range []string{"${HOME", "${}", "${a-b}", "${1x}", `"${HOME"`, "${ HOME}"} {
+		if _, err := Word(raw, lookup); !errors.Is(err, ErrBadSubstitution) {
+			t.Errorf("Word(%q) error = %v, want %v", raw, err, ErrBadSubstitution)
+		}
+	}
+
+	if _, err := HereDoc("x ${HOME\n", lookup); !errors.Is(err, ErrBadSubstitution) {
+		t.Errorf("HereDoc error = %v, want %v", err, ErrBadSubstitution)
+	}
+}
+
+func TestHereDoc(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"hi $HOME\n", "hi /home/u\n"},
+		{"${1} and $SPACED!\n", "one and a  b\tc !\n"},
+		{"$UNSET|\n", "|\n"},
+
+		// Quotes are kept and do not stop expansion
+		{`"$HOME" '$HOME'` + "\n", `"/home/u" '/home/u'` + "\n"},
+
+		// A backslash only escapes $, \ and a newline
+		{`\$HOME \\ \"x\" \n` + "\n", `$HOME \ \"x\" \n` + "\n"},
+		{"one \\\ntwo\n", "one two\n"},
+		{"cost: 5$\n", "cost: 5$\n"},
+	}
+
+	for _, test := range tests {
+		got, err := HereDoc(test.body, lookup)
+		if err != nil {
+			t.Errorf("HereDoc(%q): %v", test.body, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("HereDoc(%q) = %q, want %q", test.body, got, test.want)
+		}
+	}
+}
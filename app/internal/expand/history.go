@@ -0,0 +1,292 @@
+package expand
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/app/internal/lexer"
+)
+
+// ErrHistory is matched by every history expansion error.
+var ErrHistory = errors.New("history expansion failed")
+
+type historyError struct {
+	ref    string
+	reason string
+}
+
+func (e *historyError) Error() string {
+	return e.ref + ": " + e.reason
+}
+
+func (e *historyError) Is(target error) bool {
+	return target == ErrHistory
+}
+
+// History performs bash-style history expansion on an input line, given the
+// previous commands oldest first, and reports whether anything changed.
+//
+// Events are !! (the last command), !n, !-n, !prefix and !?text?, optionally
+// followed by a word designator such as :0, :n, :^, :$, :*, :n-m or :n*.
+// !$, !^ and !* are short for !!:$, !!:^ and !!:*. A line starting with
+// ^old^new^ repeats the last command with old replaced by new. Single
+// quotes and a backslash keep a ! literal.
+func History(line string, events []string) (string, bool, error) {
+	if strings.HasPrefix(line, "^") {
+		expanded, err := quickSubstitution(line, events)
+		return expanded, err == nil, err
+	}
+
+	var out strings.Builder
+	changed := false
+	inQuotes := false
+	inDoubleQuotes := false
+
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+
+		switch {
+		case inQuotes:
+			if ch == '\'' {
+				inQuotes = false
+			}
+		case ch == '\'' && !inDoubleQuotes:
+			inQuotes = true
+		case ch == '"':
+			inDoubleQuotes = !inDoubleQuotes
+		case ch == '\\' && i+1 < len(line):
+			out.WriteByte(ch)
+			i++
+			ch = line[i]
+		case ch == '!' && !literalBang(line, i, inDoubleQuotes):
+			text, n, err := historyRef(line[i:], events)
+			if err != nil {
+				return "", false, err
+			}
+			out.WriteString(text)
+			i += n - 1
+			changed = true
+			continue
+		}
+
+		out.WriteByte(ch)
+	}
+
+	return out.String(), changed, nil
+}
+
+// literalBang reports whether the ! at line[i] does not start a history
+// reference: at the end of the line, before a blank, = or (, or in $!.
+func literalBang(line string, i int, inDoubleQuotes bool) bool {
+	if i+1 >= len(line) || strings.IndexByte(" \t\n=(", line[i+1]) != -1 {
+		return true
+	}
+	if inDoubleQuotes && line[i+1] == '"' {
+		return true
+	}
+	return i > 0 && line[i-1] == '$'
+}
+
+// historyRef expands the reference at the start of ref and returns the text
+// along with how many bytes it took up.
+func historyRef(ref string, events []string) (string, int, error) {
+	event, n, err := historyEvent(ref, events)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// !$, !^ and !* select words of the last command without a colon
+	selector := ""
+	switch {
+	case n == 1 && strings.IndexByte("$^*", ref[1]) != -1:
+		selector = ref[1:2]
+		n++
+	case n < len(ref) && ref[n] == ':' && n+1 < len(ref) && (isWordSelector(ref[n+1]) || ref[n+1] == '-'):
+		end := n + 1
+		for end < len(ref) && (isWordSelector(ref[end]) || ref[end] == '-') {
+			end++
+		}
+		selector = ref[n+1 : end]
+		n = end
+	}
+
+	if selector == "" {
+		return event, n, nil
+	}
+
+	words, err := selectWords(event, selector)
+	if err != nil {
+		return "", 0, &historyError{ref[:n], err.Error()}
+	}
+	return words, n, nil
+}
+
+// historyEvent finds the command an event designator refers to. A bare !
+// followed by a word designator means the last command and takes one byte.
+func historyEvent(ref string, events []string) (string, int, error) {
+	last := func(n int) (string, int, error) {
+		if len(events) == 0 {
+			return "", 0, &historyError{ref[:n], "event not found"}
+		}
+		return events[len(events)-1], n, nil
+	}
+
+	switch next := ref[1]; {
+	case next == '!':
+		return last(2)
+	case next == ':' || strings.IndexByte("$^*", next) != -1:
+		return last(1)
+	case next == '?':
+		end := strings.IndexByte(ref[2:], '?')
+		n := len(ref)
+		text := ref[2:]
+		if end != -1 {
+			n = end + 3
+			text = ref[2 : end+2]
+		}
+		for i := len(events) - 1; i >= 0; i-- {
+			if strings.Contains(events[i], text) {
+				return events[i], n, nil
+			}
+		}
+		return "", 0, &historyError{ref[:n], "event not found"}
+	}
+
+	n := 1
+	if ref[n] == '-' {
+		n++
+	}
+	for n < len(ref) && isDigit(ref[n]) {
+		n++
+	}
+
+	if number, err := strconv.Atoi(ref[1:n]); err == nil {
+		index := number - 1
+		if number < 0 {
+			index = len(events) + number
+		}
+		if index < 0 || index >= len(events) {
+			return "", 0, &historyError{ref[:n], "event not found"}
+		}
+		return events[index], n, nil
+	}
+
+	// !prefix runs up to a blank, a colon or a quote
+	n = 1
+	for n < len(ref) && strings.IndexByte(" \t\n:;&|<>'\"", ref[n]) == -1 {
+		n++
+	}
+	for i := len(events) - 1; i >= 0; i-- {
+		if strings.HasPrefix(events[i], ref[1:n]) {
+			return events[i], n, nil
+		}
+	}
+	return "", 0, &historyError{ref[:n], "event not found"}
+}
+
+func isWordSelector(ch byte) bool {
+	return isDigit(ch) || ch == '^' || ch == '$' || ch == '*'
+}
+
+// selectWords picks the words of a command named by a word designator.
+func selectWords(command, selector string) (string, error) {
+	words := historyWords(command)
+	last := len(words) - 1
+	errBad := errors.New("bad word specifier")
+
+	index := func(s string) (int, bool) {
+		switch s {
+		case "^":
+			return 1, true
+		case "$":
+			return last, true
+		}
+		n, err := strconv.Atoi(s)
+		return n, err == nil
+	}
+
+	from, to := 0, 0
+	switch {
+	case selector == "*":
+		if last < 1 {
+			return "", nil
+		}
+		from, to = 1, last
+	case strings.HasSuffix(selector, "*"):
+		start, ok := index(strings.TrimSuffix(selector, "*"))
+		if !ok {
+			return "", errBad
+		}
+		from, to = start, last
+	case strings.Contains(selector, "-"):
+		parts := strings.SplitN(selector, "-", 2)
+		start, ok := 0, true
+		if parts[0] != "" {
+			start, ok = index(parts[0])
+		}
+		end := last - 1
+		if parts[1] != "" {
+			var endOk bool
+			end, endOk = index(parts[1])
+			ok = ok && endOk
+		}
+		if !ok {
+			return "", errBad
+		}
+		from, to = start, end
+	default:
+		n, ok := index(selector)
+		if !ok {
+			return "", errBad
+		}
+		from, to = n, n
+	}
+
+	if from < 0 || to > last || from > to+1 {
+		return "", errBad
+	}
+
+	return strings.Join(words[from:to+1], " "), nil
+}
+
+// historyWords splits a command into words the way the shell would, with
+// operators as words of their own.
+func historyWords(command string) []string {
+	tokens, err := lexer.Tokenize(command)
+	if err != nil {
+		return strings.Fields(command)
+	}
+
+	words := make([]string, len(tokens))
+	for i, token := range tokens {
+		words[i] = token.Value
+	}
+	return words
+}
+
+// quickSubstitution expands ^old^new^: the last command with the first
+// old replaced by new.
+func quickSubstitution(line string, events []string) (string, error) {
+	parts := strings.SplitN(line[1:], "^", 3)
+	if len(events) == 0 {
+		return "", &historyError{line, "event not found"}
+	}
+
+	old := parts[0]
+	replacement := ""
+	if len(parts) > 1 {
+		replacement = parts[1]
+	}
+	rest := ""
+	if len(parts) > 2 {
+		rest = parts[2]
+	}
+
+	last := events[len(events)-1]
+	if old == "" || !strings.Contains(last, old) {
+		return "", &historyError{line, "substitution failed"}
+	}
+
+	return strings.Replace(last, old, replacement, 1) + rest, nil
+}
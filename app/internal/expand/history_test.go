@@ -0,0 +1,105 @@
+package expand
+
+import (
+	"errors"
+	"testing"
+)
+
+var events = []string{
+	"ls -l /tmp",
+	"echo one two three",
+	"git commit -m 'a b'",
+	"grep foo file.txt | wc -l",
+}
+
+func TestHistory(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		// Events
+		{"!!", "grep foo file.txt | wc -l"},
+		{"sudo !!", "sudo grep foo file.txt | wc -l"},
+		{"!1", "ls -l /tmp"},
+		{"!-2", "git commit -m 'a b'"},
+		{"!ec", "echo one two three"},
+		{"!?commit?", "git commit -m 'a b'"},
+		{"!?commit", "git commit -m 'a b'"},
+		{"!ls; !ec", "ls -l /tmp; echo one two three"},
+
+		// Word designators
+		{"echo !$", "echo -l"},
+		{"echo !^", "echo foo"},
+		{"echo !*", "echo foo file.txt | wc -l"},
+		{"echo !2:$", "echo three"},
+		{"echo !2:^", "echo one"},
+		{"echo !2:*", "echo one two three"},
+		{"echo !2:0", "echo echo"},
+		{"echo !2:1-2", "echo one two"},
+		{"echo !2:-1", "echo echo one"},
+		{"echo !2:2*", "echo two three"},
+		{"echo !2:2-", "echo two"},
+		{"echo !git:3", "echo 'a b'"},
+		{"cd !1:$/x", "cd /tmp/x"},
+
+		// Quick substitution
+		{"^foo^bar", "grep bar file.txt | wc -l"},
+		{"^foo^bar^ -c", "grep bar file.txt | wc -l -c"},
+		{"^foo^", "grep  file.txt | wc -l"},
+	}
+
+	for _, test := range tests {
+		got, changed, err := History(test.line, events)
+		if err != nil {
+			t.Errorf("History(%q): %v", test.line, err)
+			continue
+		}
+		if got != test.want || !changed {
+			t.Errorf("History(%q) = %q, %v, want %q, true", test.line, got, changed, test.want)
+		}
+	}
+}
+
+func TestHistoryLiteralBang(t *testing.T) {
+	for _, line := range []string{
+		"echo hi",
+		"echo 'a!!'",
+		`echo \!!`,
+		"echo wow!",
+		"echo ! x",
+		"[ a != b ]",
+		"echo $!",
+		`echo "hi!"`,
+		"echo x!=(y)",
+	} {
+		got, changed, err := History(line, events)
+		if err != nil || changed || got != line {
+			t.Errorf("History(%q) = %q, %v, %v, want it unchanged", line, got, changed, err)
+		}
+	}
+}
+
+func TestHistoryErrors(t *testing.T) {
+	tests := []struct {
+		line   string
+		events []string
+	}{
+		{"!!", nil},
+		{"!$", nil},
+		{"^a^b", nil},
+		{"!99", events},
+		{"!0", events},
+		{"!-9", events},
+		{"!nope", events},
+		{"!?nope?", events},
+		{"!2:9", events},
+		{"!2:3-1x", events},
+		{"^zzz^y", events},
+	}
+
+	for _, test := range tests {
+		if _, _, err := History(test.line, test.events); !errors.Is(err, ErrHistory) {
+			t.Errorf("History(%q) error = %v, want %v", test.line, err, ErrHistory)
+		}
+	}
+}
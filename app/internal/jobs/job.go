@@ -0,0 +1,189 @@
+package jobs
+
+import (
+	"sync"
+	"syscall"
+)
+
+type State int
+
+const (
+	Running State = iota
+	Stopped
+	Done
+)
+
+func (s State) String() string {
+	switch s {
+	case Stopped:
+		return "Stopped"
+	case Done:
+		return "Done"
+	}
+	return "Running"
+}
+
+// Job is a pipeline, or an and-or list of them, started by the shell. The
+// processes of each pipeline share one process group, so that the terminal
+// and signals can be handed to them together.
+type Job struct {
+	ID      int
+	Command string
+
+	mu sync.Mutex
+	// changed is closed and replaced whenever the job changes
+	changed    chan struct{}
+	pgid       int
+	state      State
+	status     int
+	foreground bool
+	// reported is set once the current state has been shown to the user
+	reported bool
+	cancel   func()
+}
+
+func NewJob(command string) *Job {
+	return &Job{Command: command, changed: make(chan struct{})}
+}
+
+// notify wakes everyone waiting on the job. Call with mu held.
+func (j *Job) notify() {
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// Pgid is the process group of the pipeline currently running, or 0 while
+// no external process has started.
+func (j *Job) Pgid() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.pgid
+}
+
+func (j *Job) SetPgid(pgid int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.pgid = pgid
+	j.notify()
+}
+
+func (j *Job) State() State {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.state
+}
+
+// SetState records that the job's processes stopped or continued. A job that
+// is done stays done.
+func (j *Job) SetState(state State) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.state == Done || j.state == state {
+		return
+	}
+	j.state = state
+	j.reported = false
+	j.notify()
+}
+
+// Status is the exit status of a finished job.
+func (j *Job) Status() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status
+}
+
+// Finish marks the job done with the given exit status.
+func (j *Job) Finish(status int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.state = Done
+	j.status = status
+	j.reported = false
+	j.notify()
+}
+
+// Foreground reports whether the job owns the terminal, so that processes it
+// starts should take the terminal too.
+func (j *Job) Foreground() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.foreground
+}
+
+func (j *Job) SetForeground(foreground bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.foreground = foreground
+}
+
+// SetCancel sets the function that aborts the pipeline currently running.
+func (j *Job) SetCancel(cancel func()) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.cancel = cancel
+}
+
+// Interrupt aborts the pipeline currently running, as SIGINT would.
+func (j *Job) Interrupt() {
+	j.mu.Lock()
+	cancel := j.cancel
+	j.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// waitFor blocks until done reports true for the job or stop is closed. It
+// returns false when it gave up because of stop.
+func (j *Job) waitFor(done func() bool, stop <-chan struct{}) bool {
+	for {
+		j.mu.Lock()
+		if done() {
+			j.mu.Unlock()
+			return true
+		}
+		changed := j.changed
+		j.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-stop:
+			return false
+		}
+	}
+}
+
+// WaitStarted blocks until the job has a process group or is already done.
+func (j *Job) WaitStarted() int {
+	j.waitFor(func() bool { return j.pgid != 0 || j.state == Done }, nil)
+	return j.Pgid()
+}
+
+// Wait blocks until the job is done and returns its exit status.
+func (j *Job) Wait() int {
+	j.waitFor(func() bool { return j.state == Done }, nil)
+	return j.Status()
+}
+
+// WaitChange blocks until the job is no longer running and returns the
+// state it ended up in.
+func (j *Job) WaitChange() State {
+	j.waitFor(func() bool { return j.state != Running }, nil)
+	return j.State()
+}
+
+// Continue resumes a stopped job by sending SIGCONT to its process group.
+func (j *Job) Continue() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.state != Stopped {
+		return nil
+	}
+	j.state = Running
+	if j.pgid == 0 {
+		return nil
+	}
+	return syscall.Kill(-j.pgid, syscall.SIGCONT)
+}
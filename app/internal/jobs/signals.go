@@ -0,0 +1,37 @@
+package jobs
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// HandleSignals keeps keyboard signals from killing or stopping the shell.
+// SIGINT interrupts the foreground job instead, or a builtin waiting in the
+// shell. Handlers rather than ignored signals keep the defaults for children.
+func (t *Table) HandleSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTSTP)
+
+	go func() {
+		for sig := range signals {
+			if sig != syscall.SIGINT {
+				continue
+			}
+
+			t.mu.Lock()
+			job := t.foreground
+			t.mu.Unlock()
+
+			if job != nil {
+				job.Interrupt()
+				continue
+			}
+
+			select {
+			case t.interrupts <- struct{}{}:
+			default:
+			}
+		}
+	}()
+}
@@ -0,0 +1,233 @@
+package jobs
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+var ErrNoSuchJob = fmt.Errorf("no such job")
+var ErrNoCurrentJob = fmt.Errorf("no current job")
+
+// Table holds the jobs running in the background or stopped. The most
+// recently added job is the current one (%+), the one before it the
+// previous one (%-).
+type Table struct {
+	mu         sync.Mutex
+	jobs       []*Job
+	foreground *Job
+	// interrupts receives SIGINT that arrived while no job was in the foreground
+	interrupts chan struct{}
+	// Control is set when the shell owns a terminal it can hand to jobs
+	Control bool
+}
+
+func NewTable() *Table {
+	return &Table{
+		interrupts: make(chan struct{}, 1),
+		Control:    hasTerminal(),
+	}
+}
+
+// Add registers job with the number after the last job in the table. A job
+// that is already in the table keeps its number.
+func (t *Table) Add(job *Job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if slices.Contains(t.jobs, job) {
+		return
+	}
+
+	job.ID = 1
+	if len(t.jobs) > 0 {
+		job.ID = t.jobs[len(t.jobs)-1].ID + 1
+	}
+	t.jobs = append(t.jobs, job)
+}
+
+func (t *Table) Remove(job *Job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, j := range t.jobs {
+		if j == job {
+			t.jobs = append(t.jobs[:i], t.jobs[i+1:]...)
+			return
+		}
+	}
+}
+
+// List returns the jobs in the order they were added.
+func (t *Table) List() []*Job {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*Job{}, t.jobs...)
+}
+
+// Find resolves a job spec: %n, %+, %%, %- or a bare process group id.
+// An empty spec means the current job.
+func (t *Table) Find(spec string) (*Job, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch spec {
+	case "", "%", "%%", "%+":
+		if len(t.jobs) == 0 {
+			return nil, ErrNoCurrentJob
+		}
+		return t.jobs[len(t.jobs)-1], nil
+	case "%-":
+		if len(t.jobs) < 2 {
+			return nil, fmt.Errorf("%s: %w", spec, ErrNoSuchJob)
+		}
+		return t.jobs[len(t.jobs)-2], nil
+	}
+
+	number, err := strconv.Atoi(strings.TrimPrefix(spec, "%"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", spec, ErrNoSuchJob)
+	}
+
+	for _, job := range t.jobs {
+		if strings.HasPrefix(spec, "%") && job.ID == number {
+			return job, nil
+		}
+		if !strings.HasPrefix(spec, "%") && job.Pgid() == number {
+			return job, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s: %w", spec, ErrNoSuchJob)
+}
+
+// Format renders job the way the jobs builtin lists it.
+func (t *Table) Format(job *Job) string {
+	marker := ' '
+
+	t.mu.Lock()
+	if n := len(t.jobs); n > 0 && t.jobs[n-1] == job {
+		marker = '+'
+	} else if n > 1 && t.jobs[n-2] == job {
+		marker = '-'
+	}
+	t.mu.Unlock()
+
+	command := job.Command
+	state := job.State()
+	if state == Running {
+		command += " &"
+	}
+	if state == Done && job.Status() != 0 {
+		return fmt.Sprintf("[%d]%c  %-24s%s", job.ID, marker, fmt.Sprintf("Exit %d", job.Status()), command)
+	}
+
+	return fmt.Sprintf("[%d]%c  %-24s%s", job.ID, marker, state, command)
+}
+
+// Report returns the status line of job and marks its state as shown.
+func (t *Table) Report(job *Job) string {
+	line := t.Format(job)
+
+	job.mu.Lock()
+	job.reported = true
+	job.mu.Unlock()
+
+	return line
+}
+
+// Notify reports the jobs that stopped or finished since the last call and
+// drops the finished ones from the table.
+func (t *Table) Notify(w io.Writer) {
+	for _, job := range t.List() {
+		job.mu.Lock()
+		state, reported := job.state, job.reported
+		job.mu.Unlock()
+
+		if state == Running || reported {
+			continue
+		}
+
+		fmt.Fprintln(w, t.Report(job))
+		if state == Done {
+			t.Remove(job)
+		}
+	}
+}
+
+// HangUp sends SIGHUP to the jobs still in the table, waking stopped ones
+// so they can act on it, as a shell does when it exits.
+func (t *Table) HangUp() {
+	for _, job := range t.List() {
+		pgid := job.Pgid()
+		if !t.Control || pgid == 0 || job.State() == Done {
+			continue
+		}
+
+		syscall.Kill(-pgid, syscall.SIGHUP)
+		if job.State() == Stopped {
+			syscall.Kill(-pgid, syscall.SIGCONT)
+		}
+	}
+}
+
+// Foreground hands the terminal to job and waits until it finishes or
+// stops, then takes the terminal back with the modes the shell had. SIGINT
+// the shell receives meanwhile interrupts the job. Processes of a job that
+// is just starting take the terminal themselves; a job resumed with fg gets
+// it here.
+func (t *Table) Foreground(job *Job) State {
+	job.SetForeground(true)
+	defer job.SetForeground(false)
+
+	t.mu.Lock()
+	t.foreground = job
+	t.mu.Unlock()
+
+	defer func() {
+		t.mu.Lock()
+		t.foreground = nil
+		t.mu.Unlock()
+	}()
+
+	var modes *term.State
+	if t.Control {
+		modes, _ = term.GetState(TTY)
+		if pgid := job.Pgid(); pgid != 0 {
+			GiveTerminal(pgid)
+		}
+	}
+
+	state := job.WaitChange()
+
+	if t.Control {
+		ReclaimTerminal()
+		if modes != nil {
+			term.Restore(TTY, modes)
+		}
+	}
+
+	return state
+}
+
+// Wait blocks until job is done and returns its exit status. It gives up
+// and returns false when the shell receives SIGINT.
+func (t *Table) Wait(job *Job) (int, bool) {
+	// Forget an interrupt nobody was waiting for
+	select {
+	case <-t.interrupts:
+	default:
+	}
+
+	if !job.waitFor(func() bool { return job.state == Done }, t.interrupts) {
+		return 0, false
+	}
+
+	return job.Status(), true
+}
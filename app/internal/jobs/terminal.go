@@ -0,0 +1,38 @@
+package jobs
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// TTY is the descriptor of the controlling terminal, the shell's stdin.
+var TTY = int(os.Stdin.Fd())
+
+// hasTerminal reports whether the shell reads from a terminal that its own
+// process group owns, which job control needs.
+func hasTerminal() bool {
+	if !term.IsTerminal(TTY) {
+		return false
+	}
+
+	pgid, err := unix.IoctlGetInt(TTY, unix.TIOCGPGRP)
+	return err == nil && pgid == unix.Getpgrp()
+}
+
+// GiveTerminal makes pgid the foreground process group of the terminal.
+func GiveTerminal(pgid int) error {
+	return unix.IoctlSetPointerInt(TTY, unix.TIOCSPGRP, pgid)
+}
+
+// ReclaimTerminal makes the shell the foreground process group again. The
+// shell is in the background at that point, so SIGTTOU would stop it.
+func ReclaimTerminal() error {
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+
+	return GiveTerminal(unix.Getpgrp())
+}
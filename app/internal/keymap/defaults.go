@@ -0,0 +1,101 @@
+package keymap
+
+// Actions are the names of the editing actions, sorted.
+var Actions = []string{
+	"abort",
+	"accept-line",
+	"backward-char",
+	"backward-delete-char",
+	"backward-kill-word",
+	"backward-word",
+	"beginning-of-line",
+	"clear-screen",
+	"complete",
+	"delete-char",
+	"end-of-file",
+	"end-of-line",
+	"forward-char",
+	"forward-search-history",
+	"forward-word",
+	"interrupt",
+	"kill-line",
+	"kill-word",
+	"next-history",
+	"previous-history",
+	"redo",
+	"reverse-search-history",
+	"self-insert",
+	"transpose-chars",
+	"undo",
+	"unix-line-discard",
+	"unix-word-rubout",
+	"vi-movement-mode",
+	"yank",
+	"yank-pop",
+}
+
+// emacsBindings are the default emacs keys, also used in vi insert mode.
+var emacsBindings = map[string]string{
+	"\x01": "beginning-of-line",    // Ctrl-A
+	"\x02": "backward-char",        // Ctrl-B
+	"\x03": "interrupt",            // Ctrl-C
+	"\x04": "end-of-file",          // Ctrl-D
+	"\x05": "end-of-line",          // Ctrl-E
+	"\x06": "forward-char",         // Ctrl-F
+	"\x07": "abort",                // Ctrl-G
+	"\x08": "backward-delete-char", // Ctrl-H
+	"\t":   "complete",
+	"\n":   "accept-line",
+	"\x0b": "kill-line",    // Ctrl-K
+	"\x0c": "clear-screen", // Ctrl-L
+	"\r":   "accept-line",
+	"\x0e": "next-history",           // Ctrl-N
+	"\x10": "previous-history",       // Ctrl-P
+	"\x12": "reverse-search-history", // Ctrl-R
+	"\x13": "forward-search-history", // Ctrl-S
+	"\x14": "transpose-chars",        // Ctrl-T
+	"\x15": "unix-line-discard",      // Ctrl-U
+	"\x17": "unix-word-rubout",       // Ctrl-W
+	"\x19": "yank",                   // Ctrl-Y
+	"\x1f": "undo",                   // Ctrl-_
+	"\x7f": "backward-delete-char",   // Backspace
+
+	"\x1bb":    "backward-word", // Alt-B
+	"\x1bB":    "backward-word",
+	"\x1bf":    "forward-word", // Alt-F
+	"\x1bF":    "forward-word",
+	"\x1bd":    "kill-word", // Alt-D
+	"\x1bD":    "kill-word",
+	"\x1by":    "yank-pop", // Alt-Y
+	"\x1bY":    "yank-pop",
+	"\x1b\x7f": "backward-kill-word", // Alt-Backspace
+	"\x1b\x08": "backward-kill-word",
+	"\x1b\x1f": "redo", // Alt-Ctrl-_, as in Emacs
+	"\x18\x15": "undo", // Ctrl-X Ctrl-U
+
+	"\x1b[A":  "previous-history", // Up
+	"\x1b[B":  "next-history",     // Down
+	"\x1b[C":  "forward-char",     // Right
+	"\x1b[D":  "backward-char",    // Left
+	"\x1bOA":  "previous-history",
+	"\x1bOB":  "next-history",
+	"\x1bOC":  "forward-char",
+	"\x1bOD":  "backward-char",
+	"\x1b[H":  "beginning-of-line", // Home
+	"\x1b[1~": "beginning-of-line",
+	"\x1b[7~": "beginning-of-line",
+	"\x1bOH":  "beginning-of-line",
+	"\x1b[F":  "end-of-line", // End
+	"\x1b[4~": "end-of-line",
+	"\x1b[8~": "end-of-line",
+	"\x1bOF":  "end-of-line",
+	"\x1b[3~": "delete-char", // Delete
+}
+
+func defaultEmacs() *Keymap {
+	emacs := newKeymap("emacs")
+	for seq, action := range emacsBindings {
+		emacs.Bind(seq, Binding{Action: action})
+	}
+	return emacs
+}
@@ -0,0 +1,169 @@
+package keymap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxIncludeDepth stops $include loops.
+const maxIncludeDepth = 10
+
+// inputrc is the state of reading an inputrc file.
+type inputrc struct {
+	keymaps *Keymaps
+	keymap  *Keymap
+	vi      bool
+	// mode is what `set editing-mode` asked for, if anything
+	mode string
+	errs []error
+}
+
+// Read applies the bindings in an inputrc file read from input. vi tells
+// whether vi editing is on, for `$if mode=vi`. It returns the editing mode
+// a `set editing-mode` line asked for, or "" for none, and an error for
+// each line it could not use. The other lines still take effect.
+//
+// Besides bindings it understands `set editing-mode`, `set keymap`, the
+// $if mode=, $else and $endif conditionals, and $include. Other variables
+// are ignored. $if tests for other applications than gosh are false.
+func (k *Keymaps) Read(input io.Reader, name string, vi bool) (string, []error) {
+	rc := &inputrc{keymaps: k, vi: vi}
+	rc.keymap = rc.modeKeymap()
+	rc.read(input, name, 0)
+	return rc.mode, rc.errs
+}
+
+func (rc *inputrc) modeKeymap() *Keymap {
+	if rc.vi {
+		return rc.keymaps.maps["vi-insert"]
+	}
+	return rc.keymaps.maps["emacs"]
+}
+
+func (rc *inputrc) read(input io.Reader, name string, depth int) {
+	// Each $if pushes whether the lines in its branch are used
+	var active []bool
+	on := func() bool {
+		return len(active) == 0 || active[len(active)-1]
+	}
+
+	scanner := bufio.NewScanner(input)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		fail := func(err error) {
+			rc.errs = append(rc.errs, fmt.Errorf("%s: line %d: %v", name, number, err))
+		}
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		directive, arg, _ := strings.Cut(line, " ")
+		arg = strings.TrimSpace(arg)
+
+		switch directive {
+		case "$if":
+			active = append(active, on() && rc.test(arg))
+			continue
+		case "$else":
+			if len(active) == 0 {
+				fail(fmt.Errorf("$else found without matching $if"))
+				continue
+			}
+			outer := len(active) == 1 || active[len(active)-2]
+			active[len(active)-1] = outer && !active[len(active)-1]
+			continue
+		case "$endif":
+			if len(active) == 0 {
+				fail(fmt.Errorf("$endif without matching $if"))
+				continue
+			}
+			active = active[:len(active)-1]
+			continue
+		}
+
+		if !on() {
+			continue
+		}
+
+		switch directive {
+		case "$include":
+			rc.include(arg, depth, fail)
+		case "set":
+			if err := rc.set(arg); err != nil {
+				fail(err)
+			}
+		default:
+			seq, binding, err := ParseBinding(line, false)
+			if err != nil {
+				fail(err)
+				continue
+			}
+			rc.keymap.Bind(seq, binding)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		rc.errs = append(rc.errs, fmt.Errorf("%s: %v", name, err))
+	}
+}
+
+// test evaluates the condition of an $if.
+func (rc *inputrc) test(cond string) bool {
+	if mode, ok := strings.CutPrefix(cond, "mode="); ok {
+		return mode == "vi" && rc.vi || mode == "emacs" && !rc.vi
+	}
+	return cond == "gosh"
+}
+
+// set handles `set variable value`.
+func (rc *inputrc) set(arg string) error {
+	variable, value, _ := strings.Cut(arg, " ")
+	value = strings.TrimSpace(value)
+
+	switch variable {
+	case "editing-mode":
+		if value != "vi" && value != "emacs" {
+			return fmt.Errorf("%s: invalid editing mode", value)
+		}
+		rc.mode = value
+		rc.vi = value == "vi"
+		rc.keymap = rc.modeKeymap()
+	case "keymap":
+		keymap, ok := rc.keymaps.Get(value)
+		if !ok {
+			return fmt.Errorf("%s: unknown keymap", value)
+		}
+		rc.keymap = keymap
+	}
+
+	return nil
+}
+
+// include reads another inputrc file, relative to the home directory when
+// it starts with ~/.
+func (rc *inputrc) include(path string, depth int, fail func(error)) {
+	if depth >= maxIncludeDepth {
+		fail(fmt.Errorf("%s: too many nested includes", path))
+		return
+	}
+
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, rest)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer file.Close()
+
+	rc.read(file, path, depth+1)
+}
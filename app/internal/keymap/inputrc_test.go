@@ -0,0 +1,147 @@
+package keymap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	input := `# comment
+"\C-t": beginning-of-line
+
+$if mode=emacs
+"\C-o": "ls\r"
+$else
+"\C-o": abort
+$endif
+
+$if Bash
+"\C-p": abort
+$else
+$if gosh
+"\C-n": abort
+$endif
+$endif
+
+set keymap vi-command
+"gg": previous-history
+set bell-style none
+`
+
+	keymaps := New()
+	mode, errs := keymaps.Read(strings.NewReader(input), "inputrc", false)
+	if mode != "" || len(errs) != 0 {
+		t.Fatalf("Read = %q, %v", mode, errs)
+	}
+
+	emacs, _ := keymaps.Get("emacs")
+	command, _ := keymaps.Get("vi-command")
+	tests := []struct {
+		keymap *Keymap
+		seq    string
+		want   Binding
+	}{
+		{emacs, "\x14", Binding{Action: "beginning-of-line"}},
+		{emacs, "\x0f", Binding{Macro: "ls\r"}},
+		{emacs, "\x10", Binding{Action: "previous-history"}},
+		{emacs, "\x0e", Binding{Action: "abort"}},
+		{command, "gg", Binding{Action: "previous-history"}},
+	}
+	for _, test := range tests {
+		if got, _ := test.keymap.Lookup(test.seq); got != test.want {
+			t.Errorf("%s %q = %+v, want %+v", test.keymap.Name, test.seq, got, test.want)
+		}
+	}
+}
+
+func TestReadEditingMode(t *testing.T) {
+	input := `set editing-mode vi
+$if mode=vi
+"\C-l": clear-screen
+"jj": vi-movement-mode
+$endif
+`
+
+	keymaps := New()
+	mode, errs := keymaps.Read(strings.NewReader(input), "inputrc", false)
+	if mode != "vi" || len(errs) != 0 {
+		t.Fatalf("Read = %q, %v", mode, errs)
+	}
+
+	// Bindings after the mode changes go to its keymap
+	insert, _ := keymaps.Get("vi-insert")
+	if binding, _ := insert.Lookup("jj"); binding.Action != "vi-movement-mode" {
+		t.Errorf(`vi-insert "jj" = %+v`, binding)
+	}
+	emacs, _ := keymaps.Get("emacs")
+	if _, ok := emacs.Lookup("jj"); ok {
+		t.Error(`emacs got the vi binding for "jj"`)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	input := `"\C-a": beginning-of-line
+"\C-b": no-such-function
+$endif
+set editing-mode ed
+set keymap nope
+"\C-e": end-of-line
+$include /nonexistent/inputrc
+`
+
+	keymaps := New()
+	_, errs := keymaps.Read(strings.NewReader(input), "rc", false)
+
+	want := []string{
+		"rc: line 2: no-such-function: unknown function name",
+		"rc: line 3: $endif without matching $if",
+		"rc: line 4: ed: invalid editing mode",
+		"rc: line 5: nope: unknown keymap",
+		"rc: line 7: open /nonexistent/inputrc: no such file or directory",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("errors = %v, want %d of them", errs, len(want))
+	}
+	for i, err := range errs {
+		if err.Error() != want[i] {
+			t.Errorf("error %d = %q, want %q", i, err, want[i])
+		}
+	}
+
+	// The lines around the bad ones still count
+	emacs, _ := keymaps.Get("emacs")
+	if binding, _ := emacs.Lookup("\x05"); binding.Action != "end-of-line" {
+		t.Errorf(`"\C-e" = %+v`, binding)
+	}
+}
+
+func TestReadInclude(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+
+	included := filepath.Join(dir, "keys")
+	if err := os.WriteFile(included, []byte(`"\C-t": abort`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loop := filepath.Join(dir, "loop")
+	if err := os.WriteFile(loop, []byte("$include "+loop+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	keymaps := New()
+	_, errs := keymaps.Read(strings.NewReader("$include ~/keys\n"), "inputrc", false)
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	emacs, _ := keymaps.Get("emacs")
+	if binding, _ := emacs.Lookup("\x14"); binding.Action != "abort" {
+		t.Errorf(`"\C-t" = %+v`, binding)
+	}
+
+	_, errs = keymaps.Read(strings.NewReader("$include "+loop+"\n"), "inputrc", false)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "too many nested includes") {
+		t.Errorf("include loop errors = %v", errs)
+	}
+}
@@ -0,0 +1,125 @@
+package keymap
+
+import (
+	"sort"
+	"strings"
+)
+
+// Binding is what a key sequence does: run an editing action, insert the
+// keys of a macro, or run a shell command.
+type Binding struct {
+	Action  string
+	Macro   string
+	Command string
+}
+
+// Keymap maps key sequences to bindings. A sequence is the raw bytes the
+// terminal sends, such as "\x1b[A" for the up arrow.
+type Keymap struct {
+	Name     string
+	bindings map[string]Binding
+}
+
+func newKeymap(name string) *Keymap {
+	return &Keymap{Name: name, bindings: make(map[string]Binding)}
+}
+
+func (k *Keymap) clone(name string) *Keymap {
+	clone := newKeymap(name)
+	for seq, binding := range k.bindings {
+		clone.bindings[seq] = binding
+	}
+	return clone
+}
+
+// Bind makes seq do binding, replacing what it did before.
+func (k *Keymap) Bind(seq string, binding Binding) {
+	k.bindings[seq] = binding
+}
+
+// Unbind removes the binding of seq and reports whether there was one.
+func (k *Keymap) Unbind(seq string) bool {
+	_, ok := k.bindings[seq]
+	delete(k.bindings, seq)
+	return ok
+}
+
+// Lookup returns the binding of seq.
+func (k *Keymap) Lookup(seq string) (Binding, bool) {
+	binding, ok := k.bindings[seq]
+	return binding, ok
+}
+
+// IsPrefix reports whether a longer sequence bound in the keymap starts with
+// seq, so that more keys have to be read to tell what seq does.
+func (k *Keymap) IsPrefix(seq string) bool {
+	for bound := range k.bindings {
+		if len(bound) > len(seq) && strings.HasPrefix(bound, seq) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sequences returns the bound sequences in a stable order.
+func (k *Keymap) Sequences() []string {
+	seqs := make([]string, 0, len(k.bindings))
+	for seq := range k.bindings {
+		seqs = append(seqs, seq)
+	}
+	sort.Strings(seqs)
+	return seqs
+}
+
+// KeysFor returns the sequences bound to action.
+func (k *Keymap) KeysFor(action string) []string {
+	var seqs []string
+	for _, seq := range k.Sequences() {
+		if k.bindings[seq].Action == action {
+			seqs = append(seqs, seq)
+		}
+	}
+	return seqs
+}
+
+// Keymaps holds a keymap for each editing mode: emacs, vi-insert, and
+// vi-command, whose bindings take precedence over the built-in vi commands.
+type Keymaps struct {
+	maps map[string]*Keymap
+}
+
+// New returns the default keymaps.
+func New() *Keymaps {
+	emacs := defaultEmacs()
+
+	viInsert := emacs.clone("vi-insert")
+	viInsert.Bind("\x1b", Binding{Action: "vi-movement-mode"})
+
+	return &Keymaps{maps: map[string]*Keymap{
+		"emacs":      emacs,
+		"vi-insert":  viInsert,
+		"vi-command": newKeymap("vi-command"),
+	}}
+}
+
+// aliases are the other names readline knows the keymaps by.
+var aliases = map[string]string{
+	"emacs-standard": "emacs",
+	"vi":             "vi-command",
+	"vi-move":        "vi-command",
+}
+
+// Get returns the keymap called name.
+func (k *Keymaps) Get(name string) (*Keymap, bool) {
+	if alias, ok := aliases[name]; ok {
+		name = alias
+	}
+	keymap, ok := k.maps[name]
+	return keymap, ok
+}
+
+// IsAction reports whether name is an editing action keys can be bound to.
+func IsAction(name string) bool {
+	i := sort.SearchStrings(Actions, name)
+	return i < len(Actions) && Actions[i] == name
+}
@@ -0,0 +1,73 @@
+package keymap
+
+import (
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestActionsSorted(t *testing.T) {
+	// IsAction searches the list
+	if !sort.StringsAreSorted(Actions) {
+		t.Fatal("Actions is not sorted")
+	}
+	for seq, action := range emacsBindings {
+		if !IsAction(action) {
+			t.Errorf("%q is bound to unknown action %q", seq, action)
+		}
+	}
+}
+
+func TestDefaultKeymaps(t *testing.T) {
+	keymaps := New()
+
+	emacs, _ := keymaps.Get("emacs-standard")
+	if binding, _ := emacs.Lookup("\x1f"); binding.Action != "undo" {
+		t.Errorf(`emacs "\C-_" = %+v, want undo`, binding)
+	}
+	if !emacs.IsPrefix("\x1b") || !emacs.IsPrefix("\x1b[") || emacs.IsPrefix("\x1b[A") {
+		t.Error("emacs prefixes of the arrow keys are wrong")
+	}
+	if _, ok := emacs.Lookup("\x1b"); ok {
+		t.Error("emacs binds Escape on its own")
+	}
+
+	insert, _ := keymaps.Get("vi-insert")
+	if binding, _ := insert.Lookup("\x1b"); binding.Action != "vi-movement-mode" {
+		t.Errorf("vi-insert Escape = %+v, want vi-movement-mode", binding)
+	}
+
+	// The keymaps are copies, so binding in one leaves the others alone
+	insert.Bind("\x01", Binding{Action: "abort"})
+	if binding, _ := emacs.Lookup("\x01"); binding.Action != "beginning-of-line" {
+		t.Errorf("emacs Ctrl-A = %+v after rebinding it in vi-insert", binding)
+	}
+
+	command, _ := keymaps.Get("vi")
+	if command.Name != "vi-command" || len(command.Sequences()) != 0 {
+		t.Errorf("vi keymap = %s with %d bindings", command.Name, len(command.Sequences()))
+	}
+	if _, ok := keymaps.Get("nope"); ok {
+		t.Error(`Get("nope") found a keymap`)
+	}
+}
+
+func TestKeysFor(t *testing.T) {
+	keymaps := New()
+	emacs, _ := keymaps.Get("emacs")
+
+	want := []string{"\x01", "\x1bOH", "\x1b[1~", "\x1b[7~", "\x1b[H"}
+	if got := emacs.KeysFor("beginning-of-line"); !slices.Equal(got, want) {
+		t.Errorf("KeysFor = %q, want %q", got, want)
+	}
+
+	for _, seq := range want {
+		emacs.Unbind(seq)
+	}
+	if got := emacs.KeysFor("beginning-of-line"); len(got) != 0 {
+		t.Errorf("KeysFor after unbinding = %q", got)
+	}
+	if emacs.Unbind("\x01") {
+		t.Error("Unbind reported a binding that was already gone")
+	}
+}
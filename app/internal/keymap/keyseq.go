@@ -0,0 +1,274 @@
+package keymap
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseBinding parses a binding as inputrc writes it: `"keyseq": action`,
+// `keyname: action`, or a quoted macro in place of the action. With
+// command set, the rest of the line is a shell command to run instead, as
+// for `bind -x`.
+func ParseBinding(line string, command bool) (string, Binding, error) {
+	line = strings.TrimSpace(line)
+
+	var seq, rest string
+	if strings.HasPrefix(line, "\"") {
+		end := closingQuote(line, 1)
+		if end == -1 {
+			return "", Binding{}, errors.New("no closing `\"' in key binding")
+		}
+		seq = unescape(line[1:end])
+		rest = strings.TrimSpace(line[end+1:])
+		if !strings.HasPrefix(rest, ":") {
+			return "", Binding{}, fmt.Errorf("%s: missing colon separator", line)
+		}
+		rest = rest[1:]
+	} else {
+		colon := strings.IndexByte(line, ':')
+		if colon == -1 {
+			return "", Binding{}, fmt.Errorf("%s: missing colon separator", line)
+		}
+		var err error
+		if seq, err = parseKeyName(strings.TrimSpace(line[:colon])); err != nil {
+			return "", Binding{}, err
+		}
+		rest = line[colon+1:]
+	}
+
+	if seq == "" {
+		return "", Binding{}, errors.New("empty key sequence")
+	}
+
+	value := strings.TrimSpace(rest)
+
+	switch {
+	case command:
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		if value == "" {
+			return "", Binding{}, errors.New("missing shell command")
+		}
+		return seq, Binding{Command: value}, nil
+	case strings.HasPrefix(value, "\"") || strings.HasPrefix(value, "'"):
+		end := closingQuote(value, 1)
+		if end == -1 || value[end] != value[0] {
+			return "", Binding{}, errors.New("no closing quote in macro")
+		}
+		return seq, Binding{Macro: unescape(value[1:end])}, nil
+	}
+
+	// Anything after the name, such as a comment, is ignored
+	action, _, _ := strings.Cut(value, " ")
+	if !IsAction(action) {
+		return "", Binding{}, fmt.Errorf("%s: unknown function name", action)
+	}
+	return seq, Binding{Action: action}, nil
+}
+
+// ParseKeySeq parses a key sequence given with or without the quotes, as
+// `bind -r` takes it.
+func ParseKeySeq(text string) (string, error) {
+	if strings.HasPrefix(text, "\"") {
+		end := closingQuote(text, 1)
+		if end == -1 {
+			return "", errors.New("no closing `\"' in key sequence")
+		}
+		text = text[1:end]
+	}
+	seq := unescape(text)
+	if seq == "" {
+		return "", errors.New("empty key sequence")
+	}
+	return seq, nil
+}
+
+// closingQuote finds the quote that matches the one before start, skipping
+// backslash escapes.
+func closingQuote(text string, start int) int {
+	quote := text[start-1]
+	for i := start; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// unescape turns the escapes of a quoted key sequence or macro into the
+// bytes they stand for: \C- and \M- prefixes, \e, the C escapes, and octal
+// and hex codes.
+func unescape(text string) string {
+	var sb strings.Builder
+
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		if ch != '\\' || i+1 == len(text) {
+			sb.WriteByte(ch)
+			continue
+		}
+
+		i++
+		switch ch = text[i]; {
+		case ch == 'C' && strings.HasPrefix(text[i:], "C-") && i+2 < len(text):
+			i += 2
+			next := text[i]
+			// \C-\M-x and the like
+			if next == '\\' && strings.HasPrefix(text[i:], "\\M-") && i+3 < len(text) {
+				sb.WriteByte(0x1b)
+				i += 3
+				next = text[i]
+			}
+			sb.WriteByte(control(next))
+		case ch == 'M' && strings.HasPrefix(text[i:], "M-") && i+2 < len(text):
+			// The key after it is read as usual, escapes and all
+			sb.WriteByte(0x1b)
+			i++
+		case ch >= '0' && ch <= '7':
+			end := i
+			for end < len(text) && end < i+3 && text[end] >= '0' && text[end] <= '7' {
+				end++
+			}
+			code, _ := strconv.ParseUint(text[i:end], 8, 8)
+			sb.WriteByte(byte(code))
+			i = end - 1
+		case ch == 'x' && i+1 < len(text) && isHex(text[i+1]):
+			end := i + 1
+			for end < len(text) && end < i+3 && isHex(text[end]) {
+				end++
+			}
+			code, _ := strconv.ParseUint(text[i+1:end], 16, 8)
+			sb.WriteByte(byte(code))
+			i = end - 1
+		default:
+			if escaped, ok := simpleEscapes[ch]; ok {
+				sb.WriteByte(escaped)
+			} else {
+				sb.WriteByte(ch)
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+var simpleEscapes = map[byte]byte{
+	'a': 0x07,
+	'b': 0x08,
+	'd': 0x7f,
+	'e': 0x1b,
+	'f': 0x0c,
+	'n': '\n',
+	'r': '\r',
+	't': '\t',
+	'v': 0x0b,
+}
+
+func isHex(ch byte) bool {
+	return ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'f' || ch >= 'A' && ch <= 'F'
+}
+
+// control is the byte Ctrl with ch sends.
+func control(ch byte) byte {
+	if ch == '?' {
+		return 0x7f
+	}
+	return ch & 0x1f
+}
+
+// keyNames are the names inputrc gives keys.
+var keyNames = map[string]byte{
+	"del":     0x7f,
+	"rubout":  0x7f,
+	"esc":     0x1b,
+	"escape":  0x1b,
+	"lfd":     '\n',
+	"newline": '\n',
+	"ret":     '\r',
+	"return":  '\r',
+	"spc":     ' ',
+	"space":   ' ',
+	"tab":     '\t',
+}
+
+// modifiers are the prefixes of key names for Ctrl and Meta.
+var modifiers = []struct {
+	prefix string
+	meta   bool
+}{
+	{"control-", false},
+	{"c-", false},
+	{"meta-", true},
+	{"m-", true},
+}
+
+// parseKeyName parses a key named the way inputrc does outside quotes, such
+// as Control-a, M-b or RET.
+func parseKeyName(name string) (string, error) {
+	meta, ctrl := false, false
+
+	for stripped := true; stripped; {
+		stripped = false
+		for _, modifier := range modifiers {
+			if len(name) > len(modifier.prefix) && strings.HasPrefix(strings.ToLower(name), modifier.prefix) {
+				name = name[len(modifier.prefix):]
+				meta = meta || modifier.meta
+				ctrl = ctrl || !modifier.meta
+				stripped = true
+			}
+		}
+	}
+
+	var key byte
+	if code, ok := keyNames[strings.ToLower(name)]; ok {
+		key = code
+	} else if len(name) == 1 {
+		key = name[0]
+	} else {
+		return "", fmt.Errorf("%s: unknown key name", name)
+	}
+
+	if ctrl {
+		key = control(key)
+	}
+	if meta {
+		return "\x1b" + string(key), nil
+	}
+	return string(key), nil
+}
+
+// Format writes a key sequence the way inputrc and `bind -p` do.
+func Format(seq string) string {
+	var sb strings.Builder
+
+	for i := 0; i < len(seq); i++ {
+		switch ch := seq[i]; {
+		case ch == 0x1b:
+			sb.WriteString(`\e`)
+		case ch == 0x7f:
+			sb.WriteString(`\C-?`)
+		case ch < 0x20:
+			sb.WriteString(`\C-`)
+			letter := ch + '@'
+			if letter >= 'A' && letter <= 'Z' {
+				letter += 'a' - 'A'
+			}
+			sb.WriteByte(letter)
+		case ch == '"' || ch == '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(ch)
+		case ch >= 0x80:
+			fmt.Fprintf(&sb, `\%03o`, ch)
+		default:
+			sb.WriteByte(ch)
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,118 @@
+package keymap
+
+import "testing"
+
+func TestParseBinding(t *testing.T) {
+	tests := []struct {
+		line    string
+		command bool
+		seq     string
+		binding Binding
+	}{
+		{`"\C-a": beginning-of-line`, false, "\x01", Binding{Action: "beginning-of-line"}},
+		{`  "\C-x\C-u"  :  undo  `, false, "\x18\x15", Binding{Action: "undo"}},
+		{`"\e[A": previous-history`, false, "\x1b[A", Binding{Action: "previous-history"}},
+		{`"\M-b": backward-word`, false, "\x1bb", Binding{Action: "backward-word"}},
+		{`"\C-\M-h": backward-kill-word`, false, "\x1b\x08", Binding{Action: "backward-kill-word"}},
+		{`"\C-?": backward-delete-char`, false, "\x7f", Binding{Action: "backward-delete-char"}},
+		{`"\033\x41\t": complete`, false, "\x1bA\t", Binding{Action: "complete"}},
+		{`"\"q\\": abort`, false, `"q\`, Binding{Action: "abort"}},
+		{`"x": yank # a comment`, false, "x", Binding{Action: "yank"}},
+
+		// Key names outside quotes
+		{"Control-a: beginning-of-line", false, "\x01", Binding{Action: "beginning-of-line"}},
+		{"C-w: unix-word-rubout", false, "\x17", Binding{Action: "unix-word-rubout"}},
+		{"Meta-Rubout: backward-kill-word", false, "\x1b\x7f", Binding{Action: "backward-kill-word"}},
+		{"M-C-h: backward-kill-word", false, "\x1b\x08", Binding{Action: "backward-kill-word"}},
+		{"TAB: complete", false, "\t", Binding{Action: "complete"}},
+		{"Return: accept-line", false, "\r", Binding{Action: "accept-line"}},
+
+		// Macros and shell commands
+		{`"\C-l": "ls -l\r"`, false, "\x0c", Binding{Macro: "ls -l\r"}},
+		{`"\C-t": 'it'`, false, "\x14", Binding{Macro: "it"}},
+		{`"\C-g": git status`, true, "\x07", Binding{Command: "git status"}},
+		{`"\C-g": "git status"`, true, "\x07", Binding{Command: "git status"}},
+		{`"\C-g": 'echo "$READLINE_LINE"'`, true, "\x07", Binding{Command: `echo "$READLINE_LINE"`}},
+	}
+
+	for _, test := range tests {
+		seq, binding, err := ParseBinding(test.line, test.command)
+		if err != nil {
+			t.Errorf("ParseBinding(%q): %v", test.line, err)
+			continue
+		}
+		if seq != test.seq || binding != test.binding {
+			t.Errorf("ParseBinding(%q) = %q, %+v, want %q, %+v", test.line, seq, binding, test.seq, test.binding)
+		}
+	}
+}
+
+func TestParseBindingErrors(t *testing.T) {
+	tests := []struct {
+		line    string
+		command bool
+	}{
+		{`"\C-a" beginning-of-line`, false},
+		{`"\C-a: beginning-of-line`, false},
+		{"no-colon", false},
+		{`"": abort`, false},
+		{`"\C-a": no-such-function`, false},
+		{`"\C-a": "unterminated`, false},
+		{"Hyper-a: abort", false},
+		{`"\C-g":`, true},
+	}
+
+	for _, test := range tests {
+		if seq, binding, err := ParseBinding(test.line, test.command); err == nil {
+			t.Errorf("ParseBinding(%q) = %q, %+v, want an error", test.line, seq, binding)
+		}
+	}
+}
+
+func TestParseKeySeq(t *testing.T) {
+	tests := map[string]string{
+		`"\C-a"`:  "\x01",
+		`\C-a`:    "\x01",
+		`"\e[3~"`: "\x1b[3~",
+		`\M-y`:    "\x1by",
+		`abc`:     "abc",
+	}
+
+	for text, want := range tests {
+		got, err := ParseKeySeq(text)
+		if err != nil || got != want {
+			t.Errorf("ParseKeySeq(%q) = %q, %v, want %q", text, got, err, want)
+		}
+	}
+
+	for _, text := range []string{`""`, `"\C-a`, ""} {
+		if _, err := ParseKeySeq(text); err == nil {
+			t.Errorf("ParseKeySeq(%q) gave no error", text)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := map[string]string{
+		"\x01":     `\C-a`,
+		"\x1b[A":   `\e[A`,
+		"\x18\x15": `\C-x\C-u`,
+		"\x7f":     `\C-?`,
+		"\x1f":     `\C-_`,
+		`"\`:       `\"\\`,
+		"\xe9":     `\351`,
+		"abc":      "abc",
+	}
+
+	for seq, want := range tests {
+		got := Format(seq)
+		if got != want {
+			t.Errorf("Format(%q) = %q, want %q", seq, got, want)
+		}
+
+		// What bind -p prints reads back as the same keys
+		if back, err := ParseKeySeq(`"` + got + `"`); err != nil || back != seq {
+			t.Errorf("ParseKeySeq(Format(%q)) = %q, %v", seq, back, err)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package lexer
+
+import (
+	"fmt"
+	"strings"
+)
+
+type TokenType int
+
+const (
+	TokenWord     TokenType = iota
+	TokenOperator           // | || && ; &
+	TokenRedirect           // > >> &> &>> >& < << <<- <<< <& with an optional fd number
+)
+
+// Token is a piece of shell input. Word tokens keep their quotes and
+// escapes so that later expansion can tell quoted text apart.
+type Token struct {
+	Type  TokenType
+	Value string
+}
+
+var ErrUnterminatedQuote = fmt.Errorf("unexpected EOF while looking for matching quote")
+
+// operators is ordered so that longer operators are matched first.
+var operators = []string{"&>>", "<<<", "<<-", "&&", "||", ">>", "&>", ">&", "<&", "<<", "|", ";", "&", ">", "<"}
+
+var redirects = map[string]bool{
+	">": true, ">>": true, "&>": true, "&>>": true, ">&": true,
+	"<": true, "<<": true, "<<-": true, "<<<": true, "<&": true,
+}
+
+type lexer struct {
+	input   string
+	tokens  []Token
+	current strings.Builder
+}
+
+// Tokenize splits input into words, operators and redirections, honouring
+// single quotes, double quotes, backslash escapes and # comments.
+func Tokenize(input string) ([]Token, error) {
+	l := &lexer{input: input}
+
+	inQuotes := false
+	inDoubleQuotes := false
+
+	for i := 0; i < len(input); i++ {
+		ch := input[i]
+
+		if inQuotes {
+			l.current.WriteByte(ch)
+			if ch == '\'' {
+				inQuotes = false
+			}
+			continue
+		}
+
+		if inDoubleQuotes {
+			l.current.WriteByte(ch)
+			if ch == '\\' && i+1 < len(input) {
+				i++
+				l.current.WriteByte(input[i])
+			} else if ch == '"' {
+				inDoubleQuotes = false
+			}
+			continue
+		}
+
+		switch ch {
+		case ' ', '\t', '\n', '\r':
+			l.flush()
+		case '#':
+			if l.current.Len() > 0 {
+				l.current.WriteByte(ch)
+				continue
+			}
+			// A comment runs to the end of the line
+			for i+1 < len(input) && input[i+1] != '\n' {
+				i++
+			}
+		case '\\':
+			l.current.WriteByte(ch)
+			if i+1 < len(input) {
+				i++
+				l.current.WriteByte(input[i])
+			}
+		case '\'':
+			inQuotes = true
+			l.current.WriteByte(ch)
+		case '"':
+			inDoubleQuotes = true
+			l.current.WriteByte(ch)
+		default:
+			op := matchOperator(input[i:])
+			if op == "" {
+				l.current.WriteByte(ch)
+				continue
+			}
+			l.operator(op)
+			i += len(op) - 1
+		}
+	}
+
+	if inQuotes || inDoubleQuotes {
+		return nil, ErrUnterminatedQuote
+	}
+
+	l.flush()
+
+	return l.tokens, nil
+}
+
+func (l *lexer) operator(op string) {
+	if !redirects[op] {
+		l.flush()
+		l.tokens = append(l.tokens, Token{Type: TokenOperator, Value: op})
+		return
+	}
+
+	// A word made only of digits right before a redirection is its fd
+	fd := l.current.String()
+	if fd != "" && op[0] != '&' && isDigits(fd) {
+		l.current.Reset()
+		l.tokens = append(l.tokens, Token{Type: TokenRedirect, Value: fd + op})
+		return
+	}
+
+	l.flush()
+	l.tokens = append(l.tokens, Token{Type: TokenRedirect, Value: op})
+}
+
+func (l *lexer) flush() {
+	if l.current.Len() == 0 {
+		return
+	}
+	l.tokens = append(l.tokens, Token{Type: TokenWord, Value: l.current.String()})
+	l.current.Reset()
+}
+
+func matchOperator(input string) string {
+	for _, op := range operators {
+		if strings.HasPrefix(input, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package lexer
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func word(value string) Token     { return Token{Type: TokenWord, Value: value} }
+func operator(value string) Token { return Token{Type: TokenOperator, Value: value} }
+func redirect(value string) Token { return Token{Type: TokenRedirect, Value: value} }
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []Token
+	}{
+		{"", nil},
+		{"  echo   hi\tthere ", []Token{word("echo"), word("hi"), word("there")}},
+
+		// Quotes and escapes stay in the word, and hide operators
+		{`echo "a|b"`, []Token{word("echo"), word(`"a|b"`)}},
+		{`grep 'x|y' file`, []Token{word("grep"), word(`'x|y'`), word("file")}},
+		{`echo 'a "b" c'`, []Token{word("echo"), word(`'a "b" c'`)}},
+		{`echo "it's; fine"`, []Token{word("echo"), word(`"it's; fine"`)}},
+		{`echo a\|b a\ b`, []Token{word("echo"), word(`a\|b`), word(`a\ b`)}},
+		{`echo "a\"|b"`, []Token{word("echo"), word(`"a\"|b"`)}},
+		{`echo pre"mid"'post'`, []Token{word("echo"), word(`pre"mid"'post'`)}},
+
+		// Operators need no blanks around them, longest first
+		{"a|b", []Token{word("a"), operator("|"), word("b")}},
+		{"a||b&&c", []Token{word("a"), operator("||"), word("b"), operator("&&"), word("c")}},
+		{"a;b&", []Token{word("a"), operator(";"), word("b"), operator("&")}},
+
+		// Redirections take the fd number right before them
+		{"cmd >out 2>>err", []Token{word("cmd"), redirect(">"), word("out"), redirect("2>>"), word("err")}},
+		{"cmd 2>&1", []Token{word("cmd"), redirect("2>&"), word("1")}},
+		{"cmd &>all", []Token{word("cmd"), redirect("&>"), word("all")}},
+		{"cmd a2>f", []Token{word("cmd"), word("a2"), redirect(">"), word("f")}},
+		{"cat <in <<<word <<-EOF", []Token{word("cat"), redirect("<"), word("in"), redirect("<<<"), word("word"), redirect("<<-"), word("EOF")}},
+
+		// Comments start at a # that begins a word
+		{"# only a comment", nil},
+		{"echo a # comment | not a pipe", []Token{word("echo"), word("a")}},
+		{"echo a#b '#c' \\#d", []Token{word("echo"), word("a#b"), word("'#c'"), word(`\#d`)}},
+		{"echo a;# comment", []Token{word("echo"), word("a"), operator(";")}},
+		{"# one\necho two", []Token{word("echo"), word("two")}},
+	}
+
+	for _, test := range tests {
+		got, err := Tokenize(test.input)
+		if err != nil {
+			t.Errorf("Tokenize(%q): %v", test.input, err)
+			continue
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("Tokenize(%q) = %v, want %v", test.input, got, test.want)
+		}
+	}
+}
+
+func TestTokenizeUnterminatedQuote(t *testing.T) {
+	for _, input := range []string{`echo 'a`, `echo "a`, `echo "a\"`, `echo 'a'"b`} {
+		if _, err := Tokenize(input); !errors.Is(err, ErrUnterminatedQuote) {
+			t.Errorf("Tokenize(%q) error = %v, want %v", input, err, ErrUnterminatedQuote)
+		}
+	}
+}
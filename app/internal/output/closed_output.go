@@ -0,0 +1,38 @@
+package output
+
+import (
+	"io"
+	"sync"
+)
+
+// ClosedOutput stands for a closed file descriptor. Nothing can be written
+// to it; Failed reports whether anything was tried.
+type ClosedOutput struct {
+	mu     sync.Mutex
+	failed bool
+}
+
+func (co *ClosedOutput) Print(message string) {
+	co.fail()
+}
+
+func (co *ClosedOutput) PrintError(message string) {
+	co.fail()
+}
+
+func (co *ClosedOutput) WriteStream(r io.Reader) {
+	co.fail()
+}
+
+func (co *ClosedOutput) fail() {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	co.failed = true
+}
+
+// Failed reports whether something was written to the closed descriptor.
+func (co *ClosedOutput) Failed() bool {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	return co.failed
+}
@@ -0,0 +1,122 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Fd is what a file descriptor of a command refers to: something to read
+// from or something to write to.
+type Fd struct {
+	Input  io.Reader
+	Output Output
+}
+
+// FdTable holds the file descriptors a command runs with. It starts from the
+// command's standard streams and is changed by applying redirections.
+type FdTable struct {
+	fds     map[int]Fd
+	closers []io.Closer
+}
+
+func NewFdTable(stdin io.Reader, stdout, stderr Output) *FdTable {
+	return &FdTable{
+		fds: map[int]Fd{
+			0: {Input: stdin},
+			1: {Output: stdout},
+			2: {Output: stderr},
+		},
+	}
+}
+
+// Apply performs the redirections in order, which is what makes
+// `>file 2>&1` and `2>&1 >file` behave differently.
+func (t *FdTable) Apply(redirects []Redirect) error {
+	for _, redirect := range redirects {
+		switch redirect.Op {
+		case RedirectWrite, RedirectAppend:
+			fileOutput, err := NewFileOutput(redirect.Target, redirect.Op == RedirectAppend)
+			if err != nil {
+				return err
+			}
+			t.closers = append(t.closers, fileOutput)
+			t.fds[redirect.Fd] = Fd{Output: fileOutput}
+		case RedirectRead:
+			file, err := os.Open(redirect.Target)
+			if err != nil {
+				return openError(redirect.Target, err)
+			}
+			t.closers = append(t.closers, file)
+			t.fds[redirect.Fd] = Fd{Input: file}
+		case RedirectHereString:
+			t.fds[redirect.Fd] = Fd{Input: strings.NewReader(redirect.Target + "\n")}
+		case RedirectHereDoc:
+			t.fds[redirect.Fd] = Fd{Input: strings.NewReader(redirect.Target)}
+		case RedirectDup:
+			source, _ := strconv.Atoi(redirect.Target)
+			fd, ok := t.fds[source]
+			if !ok {
+				return fmt.Errorf("%d: Bad file descriptor", source)
+			}
+			t.fds[redirect.Fd] = fd
+		case RedirectClose:
+			delete(t.fds, redirect.Fd)
+		}
+	}
+
+	return nil
+}
+
+// openError describes why name could not be opened the way other shells
+// do, as in "out.txt: Permission denied".
+func openError(name string, err error) error {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+
+	message := err.Error()
+	if message != "" {
+		message = strings.ToUpper(message[:1]) + message[1:]
+	}
+	return fmt.Errorf("%s: %s", name, message)
+}
+
+// Input returns what fd reads from, or nil if it is closed.
+func (t *FdTable) Input(fd int) io.Reader {
+	return t.fds[fd].Input
+}
+
+// Output returns what fd writes to, or nil if it is closed.
+func (t *FdTable) Output(fd int) Output {
+	return t.fds[fd].Output
+}
+
+// Get returns the entry of fd and whether it is open.
+func (t *FdTable) Get(fd int) (Fd, bool) {
+	entry, ok := t.fds[fd]
+	return entry, ok
+}
+
+// Fds lists the open file descriptors in ascending order.
+func (t *FdTable) Fds() []int {
+	fds := make([]int, 0, len(t.fds))
+	for fd := range t.fds {
+		fds = append(fds, fd)
+	}
+	slices.Sort(fds)
+	return fds
+}
+
+// Close releases the files opened by redirections.
+func (t *FdTable) Close() {
+	for _, closer := range t.closers {
+		closer.Close()
+	}
+	t.closers = nil
+}
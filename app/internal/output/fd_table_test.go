@@ -0,0 +1,35 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyOpenErrors(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing")
+	if err := os.WriteFile(filepath.Join(dir, "file"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		redirect Redirect
+		want     string
+	}{
+		{Redirect{Fd: 0, Op: RedirectRead, Target: missing}, missing + ": No such file or directory"},
+		{Redirect{Fd: 1, Op: RedirectWrite, Target: dir}, dir + ": Is a directory"},
+		{Redirect{Fd: 1, Op: RedirectAppend, Target: filepath.Join(dir, "file", "x")}, filepath.Join(dir, "file", "x") + ": Not a directory"},
+		{Redirect{Fd: 0, Op: RedirectRead, Target: filepath.Join(dir, "file", "x")}, filepath.Join(dir, "file", "x") + ": Not a directory"},
+	}
+
+	for _, test := range tests {
+		table := NewFdTable(nil, nil, nil)
+		err := table.Apply([]Redirect{test.redirect})
+		table.Close()
+
+		if err == nil || err.Error() != test.want {
+			t.Errorf("Apply(%v) = %v, want %q", test.redirect, err, test.want)
+		}
+	}
+}
@@ -62,25 +62,38 @@ func (fo *FileOutput) WriteStream(r io.Reader) {
 	}
 }
 
-func NewFileOutput(filename string, append bool) Output {
+// File exposes the underlying file so child processes can write to it
+// directly.
+func (fo *FileOutput) File() *os.File {
+	return fo.file
+}
+
+func (fo *FileOutput) Close() error {
+	if fo.closed {
+		return nil
+	}
+	fo.closed = true
+	return fo.file.Close()
+}
+
+func NewFileOutput(filename string, append bool) (*FileOutput, error) {
 	var file *os.File
 	fo := &FileOutput{
 		fileName: filename,
 		closed:   false,
 	}
 
-	flags := os.O_CREATE | os.O_WRONLY
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
 	if append {
 		flags = os.O_APPEND | os.O_CREATE | os.O_WRONLY
 	}
 
 	file, err := os.OpenFile(fo.fileName, flags, 0644)
 	if err != nil {
-		fmt.Println("Error opening file:", err)
-		return nil
+		return nil, openError(filename, err)
 	}
 
 	fo.file = file
 
-	return fo
+	return fo, nil
 }
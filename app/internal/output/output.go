@@ -2,6 +2,7 @@ package output
 
 import (
 	"io"
+	"os"
 )
 
 type Output interface {
@@ -9,3 +10,10 @@ type Output interface {
 	PrintError(message string)
 	WriteStream(r io.Reader)
 }
+
+// FileBacked is implemented by outputs that write straight into an open
+// file, which a child process can then inherit instead of a copying pipe.
+// File returns nil when there is no such file.
+type FileBacked interface {
+	File() *os.File
+}
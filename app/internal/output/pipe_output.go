@@ -2,6 +2,7 @@ package output
 
 import (
 	"io"
+	"os"
 	"sync"
 )
 
@@ -19,7 +20,12 @@ func (po *PipeOutput) Print(message string) {
 func (po *PipeOutput) PrintError(message string) {
 	po.mu.Lock()
 	defer po.mu.Unlock()
-	po.Writer.Write([]byte(message))
+	po.Writer.Write([]byte(message + "\n"))
+}
+
+func (po *PipeOutput) File() *os.File {
+	file, _ := po.Writer.(*os.File)
+	return file
 }
 
 func (po *PipeOutput) WriteStream(r io.Reader) {
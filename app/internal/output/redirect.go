@@ -1,41 +1,94 @@
 package output
 
-import "slices"
-
-var redirectOutputSymbols = []string{">", "1>"}
-var redirectErrSymbols = []string{"2>", "&>"}
-var appendOutputSymbols = []string{"1>>", ">>"}
-var appendStdErrSymbols = []string{"2>>", "&>>"}
-
-func ParseRedirectIfPresent(args []string) (bool, bool, bool, bool, string) {
-	var redirectStdout, redirectStdErr, appendStdout, appendStdErr bool
-	var fileName string
-
-	for idx, arg := range args {
-		if slices.Contains(redirectOutputSymbols, arg) {
-			redirectStdout = true
-			fileName = args[idx+1]
-			return redirectStdout, redirectStdErr, appendStdout, appendStdErr, fileName
-		}
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
-		if slices.Contains(redirectErrSymbols, arg) {
-			redirectStdErr = true
-			fileName = args[idx+1]
-			return redirectStdout, redirectStdErr, appendStdout, appendStdErr, fileName
-		}
+type RedirectOp int
+
+const (
+	RedirectWrite      RedirectOp = iota // n>file
+	RedirectAppend                       // n>>file
+	RedirectRead                         // n<file
+	RedirectHereString                   // n<<<word
+	RedirectHereDoc                      // n<<word, Target holds the body
+	RedirectDup                          // n>&m, n<&m
+	RedirectClose                        // n>&-, n<&-
+)
+
+// Redirect is a single redirection of a command with its target expanded.
+// Redirections are applied left to right, so their order matters.
+type Redirect struct {
+	Fd     int
+	Op     RedirectOp
+	Target string
+}
 
-		if slices.Contains(appendOutputSymbols, arg) {
-			appendStdout = true
-			fileName = args[idx+1]
-			return redirectStdout, redirectStdErr, appendStdout, appendStdErr, fileName
+type redirectOp struct {
+	fd int
+	op RedirectOp
+}
+
+var redirectOps = map[string]redirectOp{
+	">":   {1, RedirectWrite},
+	">>":  {1, RedirectAppend},
+	"<":   {0, RedirectRead},
+	"<<<": {0, RedirectHereString},
+	"<<":  {0, RedirectHereDoc},
+	"<<-": {0, RedirectHereDoc},
+	">&":  {1, RedirectDup},
+	"<&":  {0, RedirectDup},
+}
+
+// NewRedirects turns an operator and its target word into redirections.
+// `&>file` is shorthand for `>file 2>&1` and so yields two of them.
+func NewRedirects(op, target string) ([]Redirect, error) {
+	switch op {
+	case "&>", "&>>":
+		redirectOp := RedirectWrite
+		if op == "&>>" {
+			redirectOp = RedirectAppend
 		}
+		return []Redirect{
+			{Fd: 1, Op: redirectOp, Target: target},
+			{Fd: 2, Op: RedirectDup, Target: "1"},
+		}, nil
+	}
 
-		if slices.Contains(appendStdErrSymbols, arg) {
-			appendStdErr = true
-			fileName = args[idx+1]
-			return redirectStdout, redirectStdErr, appendStdout, appendStdErr, fileName
+	symbol := strings.TrimLeft(op, "0123456789")
+	known, ok := redirectOps[symbol]
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown redirection", op)
+	}
+
+	fd := known.fd
+	explicitFd := len(symbol) < len(op)
+	if explicitFd {
+		n, err := strconv.Atoi(op[:len(op)-len(symbol)])
+		if err != nil {
+			return nil, fmt.Errorf("%s: bad file descriptor", op[:len(op)-len(symbol)])
 		}
+		fd = n
+	}
+
+	if known.op != RedirectDup {
+		return []Redirect{{Fd: fd, Op: known.op, Target: target}}, nil
+	}
+
+	if target == "-" {
+		return []Redirect{{Fd: fd, Op: RedirectClose}}, nil
+	}
+
+	if _, err := strconv.Atoi(target); err == nil {
+		return []Redirect{{Fd: fd, Op: RedirectDup, Target: target}}, nil
+	}
+
+	// `>&file` without a fd means the same as `&>file`
+	if symbol == ">&" && !explicitFd {
+		return NewRedirects("&>", target)
 	}
 
-	return redirectStdout, redirectStdErr, appendStdout, appendStdErr, fileName
+	return nil, fmt.Errorf("%s: ambiguous redirect", target)
 }
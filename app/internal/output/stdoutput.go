@@ -7,10 +7,8 @@ import (
 )
 
 type StandartOutput struct {
-	isError   bool
-	output    io.Writer
-	lastChar  byte
-	hasOutput bool
+	isError bool
+	output  io.Writer
 }
 
 func (so *StandartOutput) Print(message string) {
@@ -21,10 +19,12 @@ func (so *StandartOutput) PrintError(message string) {
 	fmt.Fprintln(so.output, message)
 }
 
+// WriteStream copies r to the output. Commands of a pipeline or of
+// background jobs stream at the same time, so what was written is tracked
+// per stream rather than on the output.
 func (so *StandartOutput) WriteStream(r io.Reader) {
-	// Reset state for new command output
-	so.lastChar = 0
-	so.hasOutput = false
+	var lastChar byte
+	hasOutput := false
 
 	writer := so.output
 
@@ -38,8 +38,8 @@ func (so *StandartOutput) WriteStream(r io.Reader) {
 				return
 			}
 			// Track the last character written
-			so.lastChar = buf[n-1]
-			so.hasOutput = true
+			lastChar = buf[n-1]
+			hasOutput = true
 		}
 		if err == io.EOF {
 			break
@@ -50,7 +50,7 @@ func (so *StandartOutput) WriteStream(r io.Reader) {
 	}
 
 	// Ensure output ends with a newline for proper prompt placement
-	if so.hasOutput && so.lastChar != '\n' && !so.isError {
+	if hasOutput && lastChar != '\n' && !so.isError {
 		writer.Write([]byte("\n"))
 	}
 }
@@ -0,0 +1,301 @@
+package reader
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/codecrafters-io/shell-starter-go/app/internal/keymap"
+)
+
+// errAcceptLine ends reading the line, which is complete.
+var errAcceptLine = errors.New("accept line")
+
+// editorAction is an editing action keys can be bound to. seq is the key
+// sequence that ran it.
+type editorAction func(r *StreamReader, seq []byte) error
+
+// editorActions are the actions of keymap.Actions by name.
+var editorActions = map[string]editorAction{
+	"abort": func(r *StreamReader, seq []byte) error {
+		r.ringBell()
+		return nil
+	},
+	"accept-line": func(r *StreamReader, seq []byte) error {
+		fmt.Print("\r\n")
+		return errAcceptLine
+	},
+	"backward-char": func(r *StreamReader, seq []byte) error {
+		r.moveCursor(r.prevPos(r.cursor))
+		return nil
+	},
+	"backward-delete-char": func(r *StreamReader, seq []byte) error {
+		r.handleBackspace()
+		return nil
+	},
+	"backward-kill-word": func(r *StreamReader, seq []byte) error {
+		r.kill(r.wordBackward(), r.cursor)
+		return nil
+	},
+	"backward-word": func(r *StreamReader, seq []byte) error {
+		r.moveCursor(r.wordBackward())
+		return nil
+	},
+	"beginning-of-line": func(r *StreamReader, seq []byte) error {
+		r.moveCursor(0)
+		return nil
+	},
+	"clear-screen": func(r *StreamReader, seq []byte) error {
+		r.clearScreen()
+		return nil
+	},
+	"complete": func(r *StreamReader, seq []byte) error {
+		r.handleTabCompletion()
+		r.tabPressed = true
+		return nil
+	},
+	"delete-char": func(r *StreamReader, seq []byte) error {
+		r.deleteChar()
+		return nil
+	},
+	"end-of-file": func(r *StreamReader, seq []byte) error {
+		// End of input, but only on an empty line
+		if len(r.buffer) == 0 {
+			return io.EOF
+		}
+		r.deleteChar()
+		return nil
+	},
+	"end-of-line": func(r *StreamReader, seq []byte) error {
+		r.moveCursor(len(r.buffer))
+		return nil
+	},
+	"forward-char": func(r *StreamReader, seq []byte) error {
+		r.moveCursor(r.nextPos(r.cursor))
+		return nil
+	},
+	"forward-search-history": func(r *StreamReader, seq []byte) error {
+		return r.search(true)
+	},
+	"forward-word": func(r *StreamReader, seq []byte) error {
+		r.moveCursor(r.wordForward())
+		return nil
+	},
+	"interrupt": func(r *StreamReader, seq []byte) error {
+		fmt.Print("^C\r\n")
+		return ErrInterrupted
+	},
+	"kill-line": func(r *StreamReader, seq []byte) error {
+		r.kill(r.cursor, len(r.buffer))
+		return nil
+	},
+	"kill-word": func(r *StreamReader, seq []byte) error {
+		r.kill(r.cursor, r.wordForward())
+		return nil
+	},
+	"next-history": func(r *StreamReader, seq []byte) error {
+		r.historyDown()
+		return nil
+	},
+	"previous-history": func(r *StreamReader, seq []byte) error {
+		r.historyUp()
+		return nil
+	},
+	"redo": func(r *StreamReader, seq []byte) error {
+		r.redoChange()
+		return nil
+	},
+	"reverse-search-history": func(r *StreamReader, seq []byte) error {
+		return r.search(false)
+	},
+	"self-insert": func(r *StreamReader, seq []byte) error {
+		return r.selfInsert(seq)
+	},
+	"transpose-chars": func(r *StreamReader, seq []byte) error {
+		r.transpose()
+		return nil
+	},
+	"undo": func(r *StreamReader, seq []byte) error {
+		r.undoChange()
+		return nil
+	},
+	"unix-line-discard": func(r *StreamReader, seq []byte) error {
+		r.kill(0, r.cursor)
+		return nil
+	},
+	"unix-word-rubout": func(r *StreamReader, seq []byte) error {
+		r.kill(r.blankWordBackward(), r.cursor)
+		return nil
+	},
+	"vi-movement-mode": func(r *StreamReader, seq []byte) error {
+		if !r.options.Vi || r.vi.command {
+			r.ringBell()
+			return nil
+		}
+		r.enterCommandMode()
+		return nil
+	},
+	"yank": func(r *StreamReader, seq []byte) error {
+		r.yank()
+		return nil
+	},
+	"yank-pop": func(r *StreamReader, seq []byte) error {
+		r.yankPop()
+		return nil
+	},
+}
+
+// currentKeymap is the keymap that decides what a key sequence starting
+// with first does in the current editing mode.
+func (r *StreamReader) currentKeymap(first byte) *keymap.Keymap {
+	if !r.options.Vi {
+		emacs, _ := r.keymaps.Get("emacs")
+		return emacs
+	}
+
+	if r.vi.command {
+		command, _ := r.keymaps.Get("vi-command")
+		if _, ok := command.Lookup(string(first)); ok || command.IsPrefix(string(first)) {
+			return command
+		}
+	}
+
+	insert, _ := r.keymaps.Get("vi-insert")
+	return insert
+}
+
+// readBinding reads the rest of a key sequence that starts with first and
+// returns what it is bound to in km. A bound sequence that also starts a
+// longer one, like Escape, is taken alone unless more keys follow right
+// away. When those keys turn out to make no longer binding, like Escape
+// then a vi command, the shorter one is taken and the rest handed back. An
+// unbound escape sequence is read to its end, so that none of it is typed
+// in.
+func (r *StreamReader) readBinding(km *keymap.Keymap, first byte) (keymap.Binding, []byte, error) {
+	seq := []byte{first}
+
+	var shorter keymap.Binding
+	shorterLen := 0
+	for {
+		binding, bound := km.Lookup(string(seq))
+		if !km.IsPrefix(string(seq)) || bound && !r.keyPending() {
+			if bound {
+				return binding, seq, nil
+			}
+			break
+		}
+		if bound {
+			shorter, shorterLen = binding, len(seq)
+		}
+
+		key, err := r.readKey()
+		if err != nil {
+			return keymap.Binding{}, nil, err
+		}
+		seq = append(seq, key)
+	}
+
+	if shorterLen > 0 && !(seq[0] == KEY_ESC && seq[1] == '[') {
+		// The keys handed back belong to whatever they start, not to the
+		// change being recorded
+		rest := seq[shorterLen:]
+		if n := len(r.recording) - len(rest); r.recording != nil && n >= 0 {
+			r.recording = r.recording[:n]
+		}
+		r.pushback = append(append([]byte(nil), rest...), r.pushback...)
+		return shorter, seq[:shorterLen], nil
+	}
+
+	if len(seq) >= 2 && seq[0] == KEY_ESC && seq[1] == '[' {
+		// Parameters go on until a final byte
+		for len(seq) == 2 || seq[len(seq)-1] < 0x40 || seq[len(seq)-1] > 0x7e {
+			key, err := r.readKey()
+			if err != nil {
+				return keymap.Binding{}, nil, err
+			}
+			seq = append(seq, key)
+		}
+	}
+
+	return keymap.Binding{}, seq, nil
+}
+
+// runBinding does what a key sequence is bound to. Unbound keys type
+// themselves in.
+func (r *StreamReader) runBinding(binding keymap.Binding, seq []byte) error {
+	switch {
+	case binding.Command != "":
+		return r.runShellCommand(binding.Command)
+	case binding.Macro != "":
+		r.replay = append([]byte(binding.Macro), r.replay...)
+		return nil
+	case binding.Action != "":
+		action, ok := editorActions[binding.Action]
+		if !ok {
+			r.ringBell()
+			return nil
+		}
+		return action(r, seq)
+	case len(seq) == 1:
+		return r.selfInsert(seq)
+	}
+	return nil
+}
+
+// selfInsert types in the character that the last key of seq starts.
+func (r *StreamReader) selfInsert(seq []byte) error {
+	ch, ok, err := r.typedChar(seq[len(seq)-1])
+	if err != nil {
+		return err
+	}
+	if ok {
+		r.handleRegularChar(ch)
+		r.lastAction = actionInsert
+	}
+	return nil
+}
+
+// search runs an incremental history search. The key that ends it without
+// accepting the line is handled as usual afterwards.
+func (r *StreamReader) search(forward bool) error {
+	key, done, err := r.searchHistory(forward)
+	if err != nil {
+		return err
+	}
+	if done {
+		return errAcceptLine
+	}
+	if key != 0 {
+		r.pushback = append(r.pushback, key)
+	}
+	return nil
+}
+
+// runShellCommand runs a command bound with `bind -x` with the terminal
+// back in its usual mode, then redraws the line. READLINE_LINE and
+// READLINE_POINT tell the command what was being edited.
+func (r *StreamReader) runShellCommand(command string) error {
+	if r.runCommand == nil {
+		r.ringBell()
+		return nil
+	}
+
+	os.Setenv("READLINE_LINE", string(r.buffer))
+	os.Setenv("READLINE_POINT", strconv.Itoa(len(string(r.buffer[:r.cursor]))))
+	defer os.Unsetenv("READLINE_LINE")
+	defer os.Unsetenv("READLINE_POINT")
+
+	fmt.Print("\r\n")
+	if err := r.disableRawMode(); err != nil {
+		return err
+	}
+	r.runCommand(command)
+	if err := r.enableRawMode(); err != nil {
+		return err
+	}
+
+	r.redrawPrompt()
+	return nil
+}
@@ -0,0 +1,224 @@
+package reader
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// killRingSize caps how many kills are remembered for yanking.
+const killRingSize = 30
+
+type editAction int
+
+const (
+	actionOther editAction = iota
+	actionKill
+	actionYank
+	actionInsert
+	// actionUndo is undo or redo, which are not changes to undo themselves
+	actionUndo
+)
+
+// killRing holds the text cut by the kill commands, most recent last.
+type killRing struct {
+	entries []string
+	// yanked is the entry yanked last and where it went in the line
+	yanked      int
+	yankedStart int
+	yankedEnd   int
+}
+
+// setLine replaces the line being edited and redraws it after the prompt.
+// The cursor is counted in characters and kept within the line.
+func (r *StreamReader) setLine(line string, cursor int) {
+	r.buffer = append(r.buffer[:0], []rune(line)...)
+	r.cursor = max(0, min(cursor, len(r.buffer)))
+
+	fmt.Print("\r\033[K")
+	r.redrawPrompt()
+}
+
+// moveCursor moves the cursor to pos within the line.
+func (r *StreamReader) moveCursor(pos int) {
+	pos = max(0, min(pos, len(r.buffer)))
+
+	if pos < r.cursor {
+		fmt.Print(backUp(r.buffer[pos:r.cursor]))
+	} else {
+		fmt.Print(string(r.buffer[r.cursor:pos]))
+	}
+	r.cursor = pos
+}
+
+// prevPos is where the character before pos starts, taking combining marks
+// along with the character they belong to.
+func (r *StreamReader) prevPos(pos int) int {
+	pos = max(pos-1, 0)
+	for pos > 0 && runeWidth(r.buffer[pos]) == 0 {
+		pos--
+	}
+	return pos
+}
+
+// nextPos is where the character after the one at pos starts.
+func (r *StreamReader) nextPos(pos int) int {
+	pos = min(pos+1, len(r.buffer))
+	for pos < len(r.buffer) && runeWidth(r.buffer[pos]) == 0 {
+		pos++
+	}
+	return pos
+}
+
+// deleteRange removes the characters from from to to and leaves the cursor
+// at from.
+func (r *StreamReader) deleteRange(from, to int) string {
+	text := string(r.buffer[from:to])
+	r.setLine(string(r.buffer[:from])+string(r.buffer[to:]), from)
+	return text
+}
+
+// insert puts text in at the cursor.
+func (r *StreamReader) insert(text string) {
+	line := string(r.buffer[:r.cursor]) + text + string(r.buffer[r.cursor:])
+	r.setLine(line, r.cursor+utf8.RuneCountInString(text))
+}
+
+// kill cuts line[from:to] into the kill ring. Kills in a row make up one
+// entry, so that a single yank brings them all back.
+func (r *StreamReader) kill(from, to int) {
+	if from == to {
+		r.lastAction = actionKill
+		return
+	}
+
+	text := r.deleteRange(from, to)
+	ring := &r.kills
+
+	if r.previousAction == actionKill && len(ring.entries) > 0 {
+		last := len(ring.entries) - 1
+		if from < r.killStart {
+			ring.entries[last] = text + ring.entries[last]
+		} else {
+			ring.entries[last] += text
+		}
+	} else {
+		ring.entries = append(ring.entries, text)
+		if len(ring.entries) > killRingSize {
+			ring.entries = ring.entries[1:]
+		}
+	}
+
+	r.killStart = from
+	r.lastAction = actionKill
+}
+
+// yank inserts the text killed last (Ctrl-Y).
+func (r *StreamReader) yank() {
+	ring := &r.kills
+	if len(ring.entries) == 0 {
+		r.ringBell()
+		return
+	}
+
+	ring.yanked = len(ring.entries) - 1
+	r.insertYank()
+}
+
+// yankPop replaces the text just yanked with the kill before it (Alt-Y).
+func (r *StreamReader) yankPop() {
+	ring := &r.kills
+	if r.previousAction != actionYank || len(ring.entries) == 0 {
+		r.ringBell()
+		return
+	}
+
+	r.deleteRange(ring.yankedStart, ring.yankedEnd)
+	ring.yanked = (ring.yanked - 1 + len(ring.entries)) % len(ring.entries)
+	r.insertYank()
+}
+
+func (r *StreamReader) insertYank() {
+	ring := &r.kills
+	text := ring.entries[ring.yanked]
+
+	ring.yankedStart = r.cursor
+	r.insert(text)
+	ring.yankedEnd = r.cursor
+	r.lastAction = actionYank
+}
+
+// deleteChar removes the character under the cursor.
+func (r *StreamReader) deleteChar() {
+	if r.cursor >= len(r.buffer) {
+		r.ringBell()
+		return
+	}
+	r.deleteRange(r.cursor, r.nextPos(r.cursor))
+}
+
+// transpose swaps the characters before and under the cursor, or the last
+// two at the end of the line, and moves past them (Ctrl-T).
+func (r *StreamReader) transpose() {
+	line := append([]rune(nil), r.buffer...)
+	if r.cursor == 0 || len(line) < 2 {
+		r.ringBell()
+		return
+	}
+
+	pos := min(r.cursor, len(line)-1)
+	line[pos-1], line[pos] = line[pos], line[pos-1]
+	r.setLine(string(line), pos+1)
+}
+
+// clearScreen clears the terminal and redraws the line at the top (Ctrl-L).
+func (r *StreamReader) clearScreen() {
+	fmt.Print("\033[H\033[2J")
+	r.redrawPrompt()
+}
+
+// isWordChar reports whether ch is part of a word for Alt-B, Alt-F and
+// Alt-D, which stop at punctuation as well as blanks.
+func isWordChar(ch rune) bool {
+	return ch == '_' || unicode.IsLetter(ch) || unicode.IsDigit(ch) || unicode.IsMark(ch)
+}
+
+// wordForward is where the word at or after the cursor ends.
+func (r *StreamReader) wordForward() int {
+	line := r.buffer
+	pos := r.cursor
+	for pos < len(line) && !isWordChar(line[pos]) {
+		pos++
+	}
+	for pos < len(line) && isWordChar(line[pos]) {
+		pos++
+	}
+	return pos
+}
+
+// wordBackward is where the word before the cursor starts.
+func (r *StreamReader) wordBackward() int {
+	line := r.buffer
+	pos := r.cursor
+	for pos > 0 && !isWordChar(line[pos-1]) {
+		pos--
+	}
+	for pos > 0 && isWordChar(line[pos-1]) {
+		pos--
+	}
+	return pos
+}
+
+// blankWordBackward is where the blank-separated word before the cursor
+// starts, as Ctrl-W kills it.
+func (r *StreamReader) blankWordBackward() int {
+	line := r.buffer
+	pos := r.cursor
+	for pos > 0 && (line[pos-1] == ' ' || line[pos-1] == '\t') {
+		pos--
+	}
+	for pos > 0 && line[pos-1] != ' ' && line[pos-1] != '\t' {
+		pos--
+	}
+	return pos
+}
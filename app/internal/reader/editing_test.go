@@ -0,0 +1,61 @@
+package reader
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/codecrafters-io/shell-starter-go/app/cmds"
+	"github.com/codecrafters-io/shell-starter-go/app/internal/keymap"
+)
+
+func newTestReader(t *testing.T, lines ...string) *StreamReader {
+	t.Helper()
+	t.Setenv("HISTFILE", filepath.Join(t.TempDir(), "history"))
+
+	history := cmds.InitHistory()
+	for _, line := range lines {
+		history.Start(line)
+		if err := history.Finish(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewStreamReader(nil, history, &cmds.Options{}, keymap.New())
+}
+
+func TestHistoryUpPutsCursorAtEnd(t *testing.T) {
+	r := newTestReader(t, "echo héllo 世界")
+
+	r.historyUp()
+	if got := string(r.buffer); got != "echo héllo 世界" {
+		t.Fatalf("buffer = %q", got)
+	}
+	if r.cursor != len(r.buffer) {
+		t.Errorf("cursor = %d, want %d", r.cursor, len(r.buffer))
+	}
+
+	r.historyDown()
+	if len(r.buffer) != 0 || r.cursor != 0 {
+		t.Errorf("after down: buffer = %q, cursor = %d", string(r.buffer), r.cursor)
+	}
+}
+
+func TestSetLineClampsCursor(t *testing.T) {
+	r := newTestReader(t)
+
+	tests := []struct {
+		line   string
+		cursor int
+		want   int
+	}{
+		{"héllo", 2, 2},
+		{"héllo", len("héllo"), 5},
+		{"héllo", -1, 0},
+		{"", 3, 0},
+	}
+	for _, test := range tests {
+		r.setLine(test.line, test.cursor)
+		if r.cursor != test.want {
+			t.Errorf("setLine(%q, %d): cursor = %d, want %d", test.line, test.cursor, r.cursor, test.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package reader
+
+import (
+	"io"
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/app/internal/expand"
+)
+
+// HereDoc is the input of a `<<word` or `<<-word` redirection. Its body is
+// read from the lines following the command.
+type HereDoc struct {
+	Delimiter string
+	StripTabs bool
+	// Expand is false when the delimiter was quoted, which keeps the body literal
+	Expand bool
+	Body   string
+}
+
+func newHereDoc(word string, stripTabs bool) *HereDoc {
+	delimiter, _ := expand.Word(word, nil)
+
+	return &HereDoc{
+		Delimiter: strings.Join(delimiter, ""),
+		StripTabs: stripTabs,
+		Expand:    !strings.ContainsAny(word, `'"\`),
+	}
+}
+
+// HasHereDocs reports whether the list still needs here-document bodies.
+func (l *CmdList) HasHereDocs() bool {
+	return len(l.hereDocs) > 0
+}
+
+// ReadHereDocs fills in the here-document bodies of the list in order,
+// pulling lines from readLine until each delimiter. Hitting the end of input
+// ends the current body early, like other shells do.
+func (l *CmdList) ReadHereDocs(readLine func() (string, error)) error {
+	for _, hereDoc := range l.hereDocs {
+		var body strings.Builder
+
+		for {
+			line, err := readLine()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			if hereDoc.StripTabs {
+				line = strings.TrimLeft(line, "\t")
+			}
+
+			if line == hereDoc.Delimiter {
+				break
+			}
+
+			body.WriteString(line)
+			body.WriteString("\n")
+		}
+
+		hereDoc.Body = body.String()
+	}
+
+	l.hereDocs = nil
+
+	return nil
+}
+
+// Content returns the text fed to the command's stdin, with parameters
+// expanded unless the delimiter was quoted.
+func (h *HereDoc) Content(lookup expand.Lookup) (string, error) {
+	if !h.Expand {
+		return h.Body, nil
+	}
+
+	return expand.HereDoc(h.Body, lookup)
+}
@@ -0,0 +1,377 @@
+package reader
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/app/internal/expand"
+	"github.com/codecrafters-io/shell-starter-go/app/internal/lexer"
+	"github.com/codecrafters-io/shell-starter-go/app/internal/output"
+)
+
+var ErrSyntax = fmt.Errorf("syntax error")
+
+// ErrIncomplete is a syntax error caused by the input ending early, after
+// an operator or inside quotes, which a continuation line can still fix.
+var ErrIncomplete = fmt.Errorf("%w", ErrSyntax)
+
+// Cmd is a simple command. Words and Redirects keep what was typed; Command,
+// Args, Assignments and Redirections are filled in on the copy returned by
+// Expand.
+type Cmd struct {
+	Words     []string
+	Redirects []*Redirect
+	Command   string
+	Args      []string
+	// Assignments are the NAME=value words before the command
+	Assignments  []string
+	Redirections []output.Redirect
+}
+
+// Redirect is a redirection operator such as `2>>` with its raw target word.
+// Here-documents carry their body in HereDoc.
+type Redirect struct {
+	Op      string
+	Target  string
+	HereDoc *HereDoc
+}
+
+type CmdsPipe struct {
+	Cmds []*Cmd
+}
+
+// ListOp tells how a pipeline is joined to the one before it in a list.
+type ListOp int
+
+const (
+	OpSeq ListOp = iota // ;
+	OpAnd               // &&
+	OpOr                // ||
+)
+
+type ListItem struct {
+	Op   ListOp
+	Pipe *CmdsPipe
+}
+
+// AndOr is a chain of pipelines joined by && and ||. The first item's Op is
+// always OpSeq. A Background chain was terminated by & and runs as a job.
+type AndOr struct {
+	Items      []*ListItem
+	Background bool
+}
+
+// CmdList is a sequence of and-or lists separated by ; or &.
+type CmdList struct {
+	Source string
+	AndOrs []*AndOr
+	// hereDocs are the here-documents whose bodies follow the command line
+	hereDocs []*HereDoc
+	// continued is set when the line ends in a backslash that escapes the
+	// newline, so that the command goes on on the next line
+	continued bool
+}
+
+// Parse turns an input line into a command list. It returns nil for a
+// blank line or one that is only a comment.
+func Parse(input string) (*CmdList, error) {
+	source := strings.TrimSpace(input)
+	if source == "" {
+		return nil, nil
+	}
+
+	// Trailing blanks matter: a backslash before one escapes it
+	tokens, err := lexer.Tokenize(input)
+	if errors.Is(err, lexer.ErrUnterminatedQuote) {
+		return nil, fmt.Errorf("%w: %v", ErrIncomplete, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSyntax, err)
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	p := &parser{tokens: tokens}
+
+	list, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	list.Source = source
+	list.hereDocs = p.hereDocs
+
+	// Comments are gone from the tokens, so a backslash ending one is not
+	// a continuation
+	last := tokens[len(tokens)-1]
+	list.continued = last.Type == lexer.TokenWord && endsWithEscape(last.Value)
+
+	return list, nil
+}
+
+type parser struct {
+	tokens   []lexer.Token
+	pos      int
+	hereDocs []*HereDoc
+}
+
+var andOrOps = map[string]ListOp{"&&": OpAnd, "||": OpOr}
+
+func (p *parser) parseList() (*CmdList, error) {
+	list := &CmdList{}
+
+	for {
+		andOr, err := p.parseAndOr()
+		if err != nil {
+			return nil, err
+		}
+		list.AndOrs = append(list.AndOrs, andOr)
+
+		token, ok := p.next()
+		if !ok {
+			return list, nil
+		}
+
+		if token.Type != lexer.TokenOperator || (token.Value != ";" && token.Value != "&") {
+			return nil, unexpected(token)
+		}
+		andOr.Background = token.Value == "&"
+
+		// A trailing ; or & just ends the list
+		if _, more := p.peek(); !more {
+			return list, nil
+		}
+	}
+}
+
+func (p *parser) parseAndOr() (*AndOr, error) {
+	andOr := &AndOr{}
+	op := OpSeq
+
+	for {
+		cmdsPipe, err := p.parseCmdsPipe()
+		if err != nil {
+			return nil, err
+		}
+		andOr.Items = append(andOr.Items, &ListItem{Op: op, Pipe: cmdsPipe})
+
+		token, ok := p.peek()
+		if !ok || token.Type != lexer.TokenOperator {
+			return andOr, nil
+		}
+
+		next, isAndOr := andOrOps[token.Value]
+		if !isAndOr {
+			return andOr, nil
+		}
+		p.pos++
+		op = next
+	}
+}
+
+func (p *parser) parseCmdsPipe() (*CmdsPipe, error) {
+	cmdsPipe := &CmdsPipe{}
+
+	for {
+		cmd, err := p.parseCommand()
+		if err != nil {
+			return nil, err
+		}
+		cmdsPipe.Cmds = append(cmdsPipe.Cmds, cmd)
+
+		token, ok := p.peek()
+		if !ok || token.Type != lexer.TokenOperator || token.Value != "|" {
+			return cmdsPipe, nil
+		}
+		p.pos++
+	}
+}
+
+func (p *parser) parseCommand() (*Cmd, error) {
+	cmd := &Cmd{}
+
+	for {
+		token, ok := p.peek()
+		if !ok || token.Type == lexer.TokenOperator {
+			break
+		}
+		p.pos++
+
+		if token.Type == lexer.TokenWord {
+			cmd.Words = append(cmd.Words, token.Value)
+			continue
+		}
+
+		target, ok := p.next()
+		if !ok || target.Type != lexer.TokenWord {
+			return nil, unexpected(target)
+		}
+
+		redirect := &Redirect{Op: token.Value, Target: target.Value}
+
+		switch strings.TrimLeft(token.Value, "0123456789") {
+		case "<<", "<<-":
+			redirect.HereDoc = newHereDoc(target.Value, strings.HasSuffix(token.Value, "-"))
+			p.hereDocs = append(p.hereDocs, redirect.HereDoc)
+		}
+
+		cmd.Redirects = append(cmd.Redirects, redirect)
+	}
+
+	if len(cmd.Words) == 0 && len(cmd.Redirects) == 0 {
+		token, ok := p.peek()
+		if !ok {
+			// The input ended right after |, && or ||
+			return nil, fmt.Errorf("%w near unexpected token `newline'", ErrIncomplete)
+		}
+		return nil, unexpected(token)
+	}
+
+	return cmd, nil
+}
+
+func (p *parser) peek() (lexer.Token, bool) {
+	if p.pos >= len(p.tokens) {
+		return lexer.Token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *parser) next() (lexer.Token, bool) {
+	token, ok := p.peek()
+	if ok {
+		p.pos++
+	}
+	return token, ok
+}
+
+func unexpected(token lexer.Token) error {
+	value := token.Value
+	if value == "" {
+		value = "newline"
+	}
+	return fmt.Errorf("%w near unexpected token `%s'", ErrSyntax, value)
+}
+
+// Expand returns a copy of the command with parameter expansion, field
+// splitting and quote removal applied to its words and redirections. Command
+// is empty when every word expanded to nothing, or there were only
+// assignments, whose values are not split.
+func (c *Cmd) Expand(lookup expand.Lookup) (*Cmd, error) {
+	expanded := &Cmd{Words: c.Words, Redirects: c.Redirects}
+
+	// Each assignment sees the ones before it, the command words don't
+	assigned := make(map[string]string)
+	valueLookup := lookup
+	if lookup != nil {
+		valueLookup = func(name string) (string, bool) {
+			if value, ok := assigned[name]; ok {
+				return value, true
+			}
+			return lookup(name)
+		}
+	}
+
+	words := c.Words
+	for len(words) > 0 && expand.IsAssignment(words[0]) {
+		name, raw, _ := strings.Cut(words[0], "=")
+		value, err := expand.Value(raw, valueLookup)
+		if err != nil {
+			return nil, err
+		}
+		assigned[name] = value
+		expanded.Assignments = append(expanded.Assignments, name+"="+value)
+		words = words[1:]
+	}
+
+	args := make([]string, 0, len(words))
+	for _, word := range words {
+		fields, err := expand.Word(word, lookup)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, fields...)
+	}
+
+	if len(args) > 0 {
+		expanded.Command = args[0]
+		expanded.Args = args[1:]
+	}
+
+	for _, redirect := range c.Redirects {
+		redirections, err := redirect.expand(lookup)
+		if err != nil {
+			return nil, err
+		}
+		expanded.Redirections = append(expanded.Redirections, redirections...)
+	}
+
+	return expanded, nil
+}
+
+func (r *Redirect) expand(lookup expand.Lookup) ([]output.Redirect, error) {
+	if r.HereDoc != nil {
+		content, err := r.HereDoc.Content(lookup)
+		if err != nil {
+			return nil, err
+		}
+		return output.NewRedirects(r.Op, content)
+	}
+
+	// The target is not split, it has to stay a single word
+	fields, err := expand.Word(r.Target, lookup)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) != 1 {
+		return nil, fmt.Errorf("%s: ambiguous redirect", r.Target)
+	}
+
+	return output.NewRedirects(r.Op, fields[0])
+}
+
+// String rebuilds the command as it was typed, minus any here-document body.
+func (c *Cmd) String() string {
+	parts := append([]string{}, c.Words...)
+	for _, redirect := range c.Redirects {
+		parts = append(parts, redirect.Op+redirect.Target)
+	}
+	return strings.Join(parts, " ")
+}
+
+func (c *CmdsPipe) String() string {
+	parts := make([]string, len(c.Cmds))
+	for i, cmd := range c.Cmds {
+		parts[i] = cmd.String()
+	}
+	return strings.Join(parts, " | ")
+}
+
+var listOpNames = map[ListOp]string{OpAnd: " && ", OpOr: " || "}
+
+func (a *AndOr) String() string {
+	var sb strings.Builder
+	for _, item := range a.Items {
+		sb.WriteString(listOpNames[item.Op])
+		sb.WriteString(item.Pipe.String())
+	}
+	return sb.String()
+}
+
+// Script is the source of the and-or list followed by the bodies of its
+// here-documents, which parses back to the same list.
+func (a *AndOr) Script() string {
+	var sb strings.Builder
+	sb.WriteString(a.String())
+	for _, item := range a.Items {
+		for _, cmd := range item.Pipe.Cmds {
+			for _, redirect := range cmd.Redirects {
+				if doc := redirect.HereDoc; doc != nil {
+					sb.WriteString("\n" + doc.Body + doc.Delimiter)
+				}
+			}
+		}
+	}
+	return sb.String()
+}
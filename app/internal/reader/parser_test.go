@@ -0,0 +1,236 @@
+package reader
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/shell-starter-go/app/internal/output"
+)
+
+func TestParseLists(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"echo hi", "echo hi"},
+		{`echo "a|b" | grep 'x|y' file`, `echo "a|b" | grep 'x|y' file`},
+		{"a|b|c", "a | b | c"},
+		{"a && b || c", "a && b || c"},
+		{"a; b;", "a; b"},
+		{"a & b", "a &; b"},
+		{"a && b & c || d &", "a && b &; c || d &"},
+		{"a|b&&c|d", "a | b && c | d"},
+	}
+
+	for _, test := range tests {
+		list, err := Parse(test.input)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.input, err)
+			continue
+		}
+		if got := listString(list); got != test.want {
+			t.Errorf("Parse(%q) = %q, want %q", test.input, got, test.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input      string
+		incomplete bool
+	}{
+		{"echo a |", true},
+		{"echo a &&", true},
+		{"echo a ||", true},
+		{"echo 'a", true},
+		{`echo "a`, true},
+		{"| echo a", false},
+		{"echo a ;;", false},
+		{"; echo a", false},
+		{"&", false},
+		{"echo a && ; b", false},
+		{"echo a | | b", false},
+		{"echo a >", false},
+		{"echo a > | b", false},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(test.input)
+		if !errors.Is(err, ErrSyntax) {
+			t.Errorf("Parse(%q) error = %v, want a syntax error", test.input, err)
+			continue
+		}
+		if got := errors.Is(err, ErrIncomplete); got != test.incomplete {
+			t.Errorf("Parse(%q): incomplete = %v, want %v (%v)", test.input, got, test.incomplete, err)
+		}
+	}
+}
+
+func TestParseStageRedirections(t *testing.T) {
+	list, err := Parse("cmd1 arg 2>err.log | >out.txt cmd2 2>&1 | cmd3 >>all.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]output.Redirect{
+		{{Fd: 2, Op: output.RedirectWrite, Target: "err.log"}},
+		{{Fd: 1, Op: output.RedirectWrite, Target: "out.txt"}, {Fd: 2, Op: output.RedirectDup, Target: "1"}},
+		{{Fd: 1, Op: output.RedirectAppend, Target: "all.log"}},
+	}
+	wantWords := [][]string{{"cmd1", "arg"}, {"cmd2"}, {"cmd3"}}
+
+	cmds := list.AndOrs[0].Items[0].Pipe.Cmds
+	if len(cmds) != len(want) {
+		t.Fatalf("got %d stages, want %d", len(cmds), len(want))
+	}
+	for i, cmd := range cmds {
+		expanded, err := cmd.Expand(nil)
+		if err != nil {
+			t.Fatalf("stage %d: %v", i, err)
+		}
+		if !slices.Equal(cmd.Words, wantWords[i]) {
+			t.Errorf("stage %d words = %q, want %q", i, cmd.Words, wantWords[i])
+		}
+		if !slices.Equal(expanded.Redirections, want[i]) {
+			t.Errorf("stage %d redirections = %v, want %v", i, expanded.Redirections, want[i])
+		}
+	}
+}
+
+func TestExpandAssignments(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		if name == "X" {
+			return "old value", true
+		}
+		return "", false
+	}
+
+	tests := []struct {
+		input       string
+		assignments []string
+		words       []string
+	}{
+		{"X=1", []string{"X=1"}, nil},
+		{"X=1 Y=$X", []string{"X=1", "Y=1"}, nil},
+		{"Y=$X cmd $X", []string{"Y=old value"}, []string{"cmd", "old", "value"}},
+		{"X=1 cmd $X", []string{"X=1"}, []string{"cmd", "old", "value"}},
+		{"cmd X=1", nil, []string{"cmd", "X=1"}},
+		{"'X'=1", nil, []string{"X=1"}},
+	}
+
+	for _, test := range tests {
+		list, err := Parse(test.input)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", test.input, err)
+		}
+		expanded, err := list.AndOrs[0].Items[0].Pipe.Cmds[0].Expand(lookup)
+		if err != nil {
+			t.Fatalf("Expand(%q): %v", test.input, err)
+		}
+
+		if !slices.Equal(expanded.Assignments, test.assignments) {
+			t.Errorf("%q: assignments = %q, want %q", test.input, expanded.Assignments, test.assignments)
+		}
+		words := expanded.Args
+		if expanded.Command != "" {
+			words = append([]string{expanded.Command}, words...)
+		}
+		if !slices.Equal(words, test.words) {
+			t.Errorf("%q: words = %q, want %q", test.input, words, test.words)
+		}
+	}
+}
+
+func TestParseComments(t *testing.T) {
+	tests := []struct {
+		input string
+		words [][]string
+	}{
+		{"", nil},
+		{"# note", nil},
+		{"   # indented note", nil},
+		{"#!/usr/bin/env gosh", nil},
+		{"echo a # trailing", [][]string{{"echo", "a"}}},
+		{"echo a#b", [][]string{{"echo", "a#b"}}},
+		{"echo '# quoted'", [][]string{{"echo", "'# quoted'"}}},
+		{"echo a; # rest", [][]string{{"echo", "a"}}},
+	}
+
+	for _, test := range tests {
+		list, err := Parse(test.input)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.input, err)
+			continue
+		}
+		if got := listWords(list); !equalWords(got, test.words) {
+			t.Errorf("Parse(%q) = %q, want %q", test.input, got, test.words)
+		}
+	}
+}
+
+func TestReadCommandComments(t *testing.T) {
+	script := "#!/usr/bin/env gosh\n# setup\necho a\n\n# done \\\necho b # last \\\n" +
+		"echo c\\\nd e\\ \n# trailing"
+	reader := NewScriptReader(strings.NewReader(script))
+
+	var got [][]string
+	for {
+		list, err := reader.ReadCommand()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("line %d: %v", reader.Line(), err)
+		}
+		got = append(got, listWords(list)...)
+	}
+
+	want := [][]string{{"echo", "a"}, {"echo", "b"}, {"echo", "cd", "e\\ "}}
+	if !equalWords(got, want) {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+}
+
+// listString writes list back out with its and-or lists ended by ; or &.
+func listString(list *CmdList) string {
+	parts := make([]string, len(list.AndOrs))
+	for i, andOr := range list.AndOrs {
+		parts[i] = andOr.String()
+		if andOr.Background {
+			parts[i] += " &"
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// listWords is the words of each simple command in list, in order.
+func listWords(list *CmdList) [][]string {
+	if list == nil {
+		return nil
+	}
+
+	var words [][]string
+	for _, andOr := range list.AndOrs {
+		for _, item := range andOr.Items {
+			for _, cmd := range item.Pipe.Cmds {
+				words = append(words, cmd.Words)
+			}
+		}
+	}
+	return words
+}
+
+func equalWords(a, b [][]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if strings.Join(a[i], "\x00") != strings.Join(b[i], "\x00") || len(a[i]) != len(b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package reader
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ScriptReader reads commands from a script file, a -c string or piped
+// input, without prompts or line editing.
+type ScriptReader struct {
+	input *bufio.Reader
+	// line is the number of the last line read
+	line int
+}
+
+func NewScriptReader(input io.Reader) *ScriptReader {
+	return &ScriptReader{input: bufio.NewReader(input)}
+}
+
+// Line is the number of the last line read, for error messages.
+func (r *ScriptReader) Line() int {
+	return r.line
+}
+
+// ReadCommand reads and parses the next command list. It returns io.EOF at
+// the end of the input.
+func (r *ScriptReader) ReadCommand() (*CmdList, error) {
+	return readCommand(r.readLine, func() {})
+}
+
+func (r *ScriptReader) readLine() (string, error) {
+	line, err := r.input.ReadString('\n')
+	if err == io.EOF && line != "" {
+		// The last line does not need a newline
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	r.line++
+	return strings.TrimSuffix(line, "\n"), nil
+}
+
+// readCommand reads lines until they make a complete command list, then
+// reads its here-documents. A line ending in a backslash, an open quote or
+// a trailing |, && or || continues on the next line; more is called before
+// each continuation line is read.
+func readCommand(readLine func() (string, error), more func()) (*CmdList, error) {
+	line, err := readLine()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		cmdList, parseErr := Parse(line)
+
+		joiner := ""
+		switch {
+		case errors.Is(parseErr, ErrIncomplete):
+			joiner = "\n"
+		case parseErr == nil && cmdList != nil && cmdList.continued:
+			line = line[:len(line)-1]
+		default:
+			if parseErr != nil || cmdList == nil || !cmdList.HasHereDocs() {
+				return cmdList, parseErr
+			}
+
+			return cmdList, cmdList.ReadHereDocs(func() (string, error) {
+				more()
+				return readLine()
+			})
+		}
+
+		more()
+		next, err := readLine()
+		if err == io.EOF && parseErr != nil {
+			return nil, parseErr
+		}
+		if err != nil {
+			return nil, err
+		}
+		line += joiner + next
+	}
+}
+
+// endsWithEscape reports whether a word ends in a backslash that escapes
+// the newline rather than another backslash.
+func endsWithEscape(line string) bool {
+	trailing := len(line) - len(strings.TrimRight(line, `\`))
+	return trailing%2 == 1
+}
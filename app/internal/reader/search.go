@@ -0,0 +1,141 @@
+package reader
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// historySearch is an incremental search through the history, as started by
+// Ctrl-R or Ctrl-S.
+type historySearch struct {
+	lines   []string
+	query   string
+	forward bool
+	// index is the entry matched, or len(lines) before anything matched
+	index int
+	// match is where the query starts in the entry matched
+	match  int
+	failed bool
+}
+
+// find looks for the query from entry from on, in the search direction,
+// skipping entries equal to skip.
+func (s *historySearch) find(from int, skip string) bool {
+	step := -1
+	if s.forward {
+		step = 1
+	}
+
+	for i := from; i >= 0 && i < len(s.lines); i += step {
+		if s.lines[i] == skip {
+			continue
+		}
+		if pos := strings.Index(s.lines[i], s.query); pos != -1 {
+			s.index, s.match = i, pos
+			s.failed = false
+			return true
+		}
+	}
+
+	s.failed = true
+	return false
+}
+
+// current is the entry matched, if any.
+func (s *historySearch) current() (string, bool) {
+	if s.index < 0 || s.index >= len(s.lines) {
+		return "", false
+	}
+	return s.lines[s.index], true
+}
+
+// searchHistory runs an incremental history search. Typing extends the
+// query and backspace takes it back; Ctrl-R and Ctrl-S move on to the next
+// older or newer match. Enter runs the match and Ctrl-G gives up on the
+// search. Any other key leaves the match on the line for editing and is
+// returned to be handled as usual. done reports that Enter was pressed.
+func (r *StreamReader) searchHistory(forward bool) (key byte, done bool, err error) {
+	original, originalCursor := string(r.buffer), r.cursor
+
+	lines := r.history.Lines()
+	search := historySearch{lines: lines, forward: forward, index: len(lines)}
+	// Each change to the query pushes the search before it, for backspace
+	var previous []historySearch
+
+	for {
+		r.drawSearch(&search, original)
+
+		key, err = r.readKey()
+		if err != nil {
+			return 0, false, err
+		}
+		ch, printable, err := r.typedChar(key)
+		if err != nil {
+			return 0, false, err
+		}
+
+		switch {
+		case key == KEY_CTRL_R || key == KEY_CTRL_S:
+			search.forward = key == KEY_CTRL_S
+			if search.query == "" {
+				continue
+			}
+			line, _ := search.current()
+			step := -1
+			if search.forward {
+				step = 1
+			}
+			// A failed search keeps showing the last match
+			search.find(search.index+step, line)
+		case key == KEY_BACKSPACE || key == KEY_CTRL_H:
+			if len(previous) > 0 {
+				search = previous[len(previous)-1]
+				previous = previous[:len(previous)-1]
+			}
+		case key == KEY_CTRL_G:
+			r.setLine(original, originalCursor)
+			return 0, false, nil
+		case printable:
+			previous = append(previous, search)
+			search.query += string(ch)
+			from := min(search.index, len(lines)-1)
+			if search.forward && search.index == len(lines) {
+				from = len(lines)
+			}
+			search.find(from, "")
+		default:
+			line, ok := search.current()
+			if ok {
+				r.setLine(line, utf8.RuneCountInString(line[:search.match]))
+			} else {
+				r.setLine(original, originalCursor)
+			}
+			if key == KEY_ENTER || key == KEY_CTRL_J {
+				fmt.Print("\r\n")
+				return 0, true, nil
+			}
+			return key, false, nil
+		}
+	}
+}
+
+// drawSearch shows the search prompt in place of the line being edited.
+func (r *StreamReader) drawSearch(search *historySearch, original string) {
+	label := "reverse-i-search"
+	if search.forward {
+		label = "i-search"
+	}
+	if search.failed {
+		label = "failed " + label
+	}
+
+	line, ok := search.current()
+	match := search.match
+	if !ok {
+		line, match = original, len(original)
+	}
+
+	fmt.Printf("\r\033[K(%s)'%s': %s", label, search.query, line)
+	fmt.Print(backUp([]rune(line[match:])))
+}
@@ -1,49 +1,100 @@
 package reader
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/codecrafters-io/shell-starter-go/app/cmds"
 	"github.com/codecrafters-io/shell-starter-go/app/internal/autocompletition"
+	"github.com/codecrafters-io/shell-starter-go/app/internal/expand"
+	"github.com/codecrafters-io/shell-starter-go/app/internal/keymap"
+	"golang.org/x/sys/unix"
 	"golang.org/x/term"
 )
 
 const (
+	PROMPT              = "$ "
+	CONTINUATION_PROMPT = "> "
+)
+
+const (
+	KEY_CTRL_C    = 3
+	KEY_CTRL_D    = 4
+	KEY_CTRL_G    = 7
+	KEY_CTRL_H    = 8
 	KEY_TAB       = 9
 	KEY_ENTER     = 13
 	KEY_CTRL_J    = 10
+	KEY_CTRL_R    = 18
+	KEY_CTRL_S    = 19
 	KEY_BACKSPACE = 127
 	KEY_ESC       = 27
 	KEY_DEL       = 127
 )
 
+// escapeTimeout is how many milliseconds to wait after Escape for the rest
+// of a sequence.
+const escapeTimeout = 25
+
+// ErrInterrupted is returned when Ctrl-C discards the line being read.
+var ErrInterrupted = errors.New("interrupted")
+
 type StreamReader struct {
-	tabPressed    bool
-	buffer        strings.Builder
+	prompt     string
+	tabPressed bool
+	// buffer is the line being edited and cursor the character the
+	// terminal cursor is on
+	buffer        []rune
 	cursor        int
 	trie          *autocompletition.TrieNode
 	history       *cmds.History
 	originalState *term.State
+	kills         killRing
+	// killStart is where the last kill happened, to join kills in a row
+	killStart int
+	// lastAction is what the key just handled did, and previousAction what
+	// the one before it did
+	lastAction     editAction
+	previousAction editAction
+	// browsing is set while the line is a command recalled from history
+	browsing bool
+	options  *cmds.Options
+	vi       viState
+	// undo holds the earlier versions of the line, most recent last, and
+	// redo the ones undone
+	undo []lineState
+	redo []lineState
+	// replay holds keys to handle before reading more, and replayed is set
+	// when the last key came from it
+	replay   []byte
+	replayed bool
+	// recording collects the keys read while it is not nil
+	recording []byte
+	// pushback holds keys read ahead that are still to be handled
+	pushback   []byte
+	keymaps    *keymap.Keymaps
+	runCommand func(command string)
 }
 
-type Cmd struct {
-	Command string
-	Args    []string
-}
-
-type CmdsPipe struct {
-	Cmds []*Cmd
-}
-
-func NewStreamReader(trie *autocompletition.TrieNode, history *cmds.History) *StreamReader {
+func NewStreamReader(trie *autocompletition.TrieNode, history *cmds.History, options *cmds.Options, keymaps *keymap.Keymaps) *StreamReader {
 	return &StreamReader{
+		prompt:  PROMPT,
 		trie:    trie,
 		history: history,
+		options: options,
+		keymaps: keymaps,
 	}
 }
 
+// OnCommand sets how to run the shell commands keys are bound to.
+func (r *StreamReader) OnCommand(run func(command string)) {
+	r.runCommand = run
+}
+
 func (r *StreamReader) enableRawMode() error {
 	fd := int(os.Stdin.Fd())
 
@@ -77,97 +128,187 @@ func (r *StreamReader) disableRawMode() error {
 	return nil
 }
 
-func (r *StreamReader) ReadCommand() (*CmdsPipe, error) {
-	r.buffer.Reset()
-	r.cursor = 0
-
+// ReadCommand reads and parses one command line. Continuation lines and
+// here-document bodies are read under a continuation prompt.
+func (r *StreamReader) ReadCommand() (*CmdList, error) {
 	if err := r.enableRawMode(); err != nil {
 		return nil, err
 	}
 	defer r.disableRawMode()
 
+	defer func() { r.prompt = PROMPT }()
+
+	return readCommand(r.readExpandedLine, func() {
+		r.prompt = CONTINUATION_PROMPT
+		fmt.Print(r.prompt)
+	})
+}
+
+// readExpandedLine reads a line and applies history expansion to it,
+// echoing the result when it differs from what was typed.
+func (r *StreamReader) readExpandedLine() (string, error) {
+	line, err := r.readLine()
+	if err != nil {
+		return "", err
+	}
+
+	expanded, changed, err := expand.History(line, r.history.Lines())
+	if err != nil {
+		return "", err
+	}
+	if changed {
+		fmt.Print(expanded + "\r\n")
+	}
+
+	return expanded, nil
+}
+
+func (r *StreamReader) readLine() (string, error) {
+	r.buffer = r.buffer[:0]
+	r.cursor = 0
+	r.tabPressed = false
+	r.browsing = false
+	r.lastAction = actionOther
+	r.undo = r.undo[:0]
+	r.redo = r.redo[:0]
+	r.vi.command = false
+	r.recording = nil
+
 	for {
-		char := make([]byte, 1)
-		n, err := os.Stdin.Read(char)
+		key, err := r.readKey()
 		if err != nil {
-			return nil, err
+			return "", err
 		}
-		if n == 0 {
+
+		km := r.currentKeymap(key)
+
+		// Vi commands are built in, apart from the control keys and what
+		// the vi-command keymap binds
+		if r.options.Vi && r.vi.command && key >= 32 && km.Name != "vi-command" {
+			if err := r.viCommand(key); err != nil {
+				return "", err
+			}
 			continue
 		}
 
-		switch char[0] {
-		case KEY_CTRL_J:
-			fmt.Print("\r\n")
-			return r.parseCmdsPipe()
-		case KEY_ENTER:
-			fmt.Print("\r\n")
-			return r.parseCmdsPipe()
-		case KEY_TAB:
-			r.handleTabCompletion()
-			r.tabPressed = true
-		case KEY_BACKSPACE:
-			r.handleBackspace()
-		case KEY_ESC:
-			r.handleEscapeSequence()
-		default:
-			if char[0] >= 32 && char[0] < 127 { // Printable ASCII characters
-				r.handleRegularChar(char[0])
-			}
+		binding, seq, err := r.readBinding(km, key)
+		if err != nil {
+			return "", err
+		}
+
+		r.previousAction, r.lastAction = r.lastAction, actionOther
+		before := r.lineState()
+
+		err = r.runBinding(binding, seq)
+		r.trackChange(before)
+		if errors.Is(err, errAcceptLine) {
+			return string(r.buffer), nil
+		}
+		if err != nil {
+			return "", err
 		}
 	}
 }
 
-func (r *StreamReader) handleRegularChar(ch byte) {
-	// Insert character at cursor position
-	current := r.buffer.String()
-	if r.cursor >= len(current) {
-		r.buffer.WriteByte(ch)
-		r.cursor++
-		fmt.Printf("%c", ch)
-	} else {
-		// Insert in middle
-		before := current[:r.cursor]
-		after := current[r.cursor:]
-		r.buffer.Reset()
-		r.buffer.WriteString(before)
-		r.buffer.WriteByte(ch)
-		r.buffer.WriteString(after)
-		r.cursor++
-
-		// Redraw line from cursor position
-		fmt.Printf("%c%s", ch, after)
-		// Move cursor back to correct position
-		for i := 0; i < len(after); i++ {
-			fmt.Print("\b")
+// typedChar decodes the character that key starts, reading the rest of it
+// when it takes more than one byte of UTF-8. It reports false for control
+// keys and invalid input.
+func (r *StreamReader) typedChar(key byte) (rune, bool, error) {
+	if key < utf8.RuneSelf {
+		return rune(key), key >= 32 && key < 127, nil
+	}
+
+	encoded := []byte{key}
+	for !utf8.FullRune(encoded) {
+		next, err := r.readKey()
+		if err != nil {
+			return 0, false, err
 		}
+		encoded = append(encoded, next)
 	}
+
+	ch, _ := utf8.DecodeRune(encoded)
+	return ch, ch != utf8.RuneError && unicode.IsGraphic(ch), nil
+}
+
+// readKey reads the next byte typed, or replays one.
+func (r *StreamReader) readKey() (byte, error) {
+	// A key handed back was recorded when it was first read
+	if len(r.pushback) > 0 {
+		key := r.pushback[0]
+		r.pushback = r.pushback[1:]
+		r.replayed = false
+		return key, nil
+	}
+
+	r.replayed = len(r.replay) > 0
+	if r.replayed {
+		key := r.replay[0]
+		r.replay = r.replay[1:]
+		r.record(key)
+		return key, nil
+	}
+
+	char := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(char)
+		if err != nil {
+			return 0, err
+		}
+		if n > 0 {
+			r.record(char[0])
+			return char[0], nil
+		}
+	}
+}
+
+// keyPending reports whether another key follows right away, which tells
+// the Escape key apart from the sequences that start with it.
+func (r *StreamReader) keyPending() bool {
+	// A replayed key is followed by the rest of the replay only
+	if r.replayed {
+		return len(r.replay) > 0
+	}
+
+	fds := []unix.PollFd{{Fd: int32(os.Stdin.Fd()), Events: unix.POLLIN}}
+	n, err := unix.Poll(fds, escapeTimeout)
+	return err == nil && n > 0
+}
+
+func (r *StreamReader) handleRegularChar(ch rune) {
+	// Insert character at cursor position
+	r.buffer = append(r.buffer, 0)
+	copy(r.buffer[r.cursor+1:], r.buffer[r.cursor:])
+	r.buffer[r.cursor] = ch
+	r.cursor++
+
+	// Redraw line from cursor position
+	after := r.buffer[r.cursor:]
+	fmt.Print(string(ch) + string(after))
+	// Move cursor back to correct position
+	fmt.Print(backUp(after))
 }
 
 func (r *StreamReader) handleBackspace() {
 	if r.cursor > 0 {
-		current := r.buffer.String()
-		before := current[:r.cursor-1]
-		after := current[r.cursor:]
+		from := r.prevPos(r.cursor)
+		removed := backUp(r.buffer[from:r.cursor])
 
-		r.buffer.Reset()
-		r.buffer.WriteString(before)
-		r.buffer.WriteString(after)
-		r.cursor--
+		r.buffer = append(r.buffer[:from], r.buffer[r.cursor:]...)
+		r.cursor = from
+		after := r.buffer[r.cursor:]
 
 		// Move cursor back, clear to end of line, rewrite, then position cursor
-		fmt.Print("\b")
+		fmt.Print(removed)
 		fmt.Print("\033[K") // Clear to end of line
-		fmt.Print(after)
+		fmt.Print(string(after))
 		// Move cursor back to correct position
-		for i := 0; i < len(after); i++ {
-			fmt.Print("\b")
-		}
+		fmt.Print(backUp(after))
 	}
 }
 
 func (r *StreamReader) handleTabCompletion() {
-	current := r.buffer.String()
+	current := string(r.buffer)
 	words := strings.Fields(current)
 
 	if len(words) == 0 {
@@ -211,43 +352,29 @@ func (r *StreamReader) handleTabCompletion() {
 	}
 }
 
-func (r *StreamReader) handleEscapeSequence() {
-	// Read the next two characters for arrow keys, etc.
-	seq := make([]byte, 2)
-	os.Stdin.Read(seq)
-
-	if seq[0] == '[' {
-		switch seq[1] {
-		case 'A': // Up arrow
-			cmd := r.history.Up()
-			current := r.buffer.String()
-
-			if cmd != "" {
-				r.replaceLastWord(current, cmd)
-			}
-		case 'B': // Down arrow
-			cmd := r.history.Down()
-			current := r.buffer.String()
+// historyUp replaces the line with the previous command in the history.
+func (r *StreamReader) historyUp() {
+	if cmd := r.history.Up(); cmd != "" {
+		r.browsing = true
+		r.setLine(cmd, utf8.RuneCountInString(cmd))
+	}
+}
 
-			if cmd != "" {
-				r.replaceLastWord(current, cmd)
-			}
-		case 'C': // Right arrow
-			if r.cursor < r.buffer.Len() {
-				r.cursor++
-				fmt.Print("\033[C")
-			}
-		case 'D': // Left arrow
-			if r.cursor > 0 {
-				r.cursor--
-				fmt.Print("\033[D")
-			}
-		}
+// historyDown replaces the line with the next command in the history, or
+// empties it past the last one.
+func (r *StreamReader) historyDown() {
+	if !r.browsing {
+		r.ringBell()
+		return
 	}
+
+	cmd := r.history.Down()
+	r.browsing = cmd != ""
+	r.setLine(cmd, utf8.RuneCountInString(cmd))
 }
 
 func (r *StreamReader) addSpace() {
-	r.buffer.WriteRune(' ')
+	r.buffer = append(r.buffer, ' ')
 	r.cursor++
 	fmt.Print(" ")
 }
@@ -257,23 +384,9 @@ func (r *StreamReader) ringBell() {
 }
 
 func (r *StreamReader) replaceLastWord(oldWord, newWord string) {
-	// Calculate how many characters to backspace
-	backspaceCount := len(oldWord)
-
-	// Backspace to remove old word
-	for i := 0; i < backspaceCount; i++ {
-		fmt.Print("\b \b")
-	}
-
-	// Update buffer
-	current := r.buffer.String()
+	current := string(r.buffer)
 	newContent := current[:len(current)-len(oldWord)] + newWord
-	r.buffer.Reset()
-	r.buffer.WriteString(newContent)
-	r.cursor = r.buffer.Len()
-
-	// Print new word
-	fmt.Print(newWord)
+	r.setLine(newContent, utf8.RuneCountInString(newContent))
 }
 
 func (r *StreamReader) showCompletions(completions []string) {
@@ -290,137 +403,8 @@ func (r *StreamReader) showCompletions(completions []string) {
 }
 
 func (r *StreamReader) redrawPrompt() {
-	fmt.Print("$ ")
-	current := r.buffer.String()
-	fmt.Print(current)
+	fmt.Print(r.prompt)
+	fmt.Print(string(r.buffer))
 	// Position cursor correctly
-	for i := r.buffer.Len(); i > r.cursor; i-- {
-		fmt.Print("\b")
-	}
-}
-
-func (r *StreamReader) parseCmdsPipe() (*CmdsPipe, error) {
-	var cmds []*Cmd
-
-	input := strings.TrimSpace(r.buffer.String())
-	if input == "" {
-		return nil, nil
-	}
-
-	parts := strings.Split(input, "|")
-
-	for _, part := range parts {
-		cmd := strings.TrimSpace(part)
-		if cmd == "" {
-			continue
-		}
-
-		parsedCmd, err := parseCommand(cmd)
-		if err != nil {
-			return nil, err
-		}
-
-		cmds = append(cmds, parsedCmd)
-	}
-
-	return &CmdsPipe{
-		Cmds: cmds,
-	}, nil
-}
-
-func parseCommand(input string) (*Cmd, error) {
-	// Use similar parsing logic as the original args package
-	args := make([]string, 0)
-	inQuotes := false
-	inDoubleQuotes := false
-	preserveBackslash := false
-
-	var currentArg strings.Builder
-
-	for i := 0; i < len(input); i++ {
-		ch := input[i]
-
-		preservedSymbols := []byte{'"', '$', '\\'}
-		contains := func(slice []byte, item byte) bool {
-			for _, v := range slice {
-				if v == item {
-					return true
-				}
-			}
-			return false
-		}
-
-		if contains(preservedSymbols, ch) && preserveBackslash {
-			currentArg.WriteByte(input[i])
-			preserveBackslash = false
-			continue
-		}
-
-		if ch == '\\' {
-			if inQuotes {
-				currentArg.WriteByte(input[i])
-				i++
-				if i < len(input) {
-					currentArg.WriteByte(input[i])
-				}
-				continue
-			}
-
-			if inDoubleQuotes && !preserveBackslash {
-				preserveBackslash = true
-				continue
-			}
-		}
-
-		if ch == '"' && !inQuotes {
-			inDoubleQuotes = !inDoubleQuotes
-			continue
-		}
-
-		if ch == '\'' && !inDoubleQuotes {
-			inQuotes = !inQuotes
-			continue
-		}
-
-		if ch == '\\' && !inQuotes && !inDoubleQuotes {
-			i++
-			if i < len(input) {
-				currentArg.WriteByte(input[i])
-			}
-			continue
-		}
-
-		if !inQuotes && !inDoubleQuotes && ch == ' ' {
-			if currentArg.Len() > 0 {
-				args = append(args, currentArg.String())
-				currentArg.Reset()
-			}
-			continue
-		}
-
-		if preserveBackslash {
-			currentArg.WriteByte('\\')
-			preserveBackslash = false
-		}
-
-		currentArg.WriteByte(ch)
-	}
-
-	if currentArg.Len() > 0 {
-		args = append(args, currentArg.String())
-	}
-
-	if len(args) == 0 {
-		return nil, nil
-	}
-
-	command := args[0]
-	cmdArgs := args[1:]
-
-	parsedCmd := &Cmd{
-		Command: command,
-		Args:    cmdArgs,
-	}
-
-	return parsedCmd, nil
+	fmt.Print(backUp(r.buffer[r.cursor:]))
 }
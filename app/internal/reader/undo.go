@@ -0,0 +1,74 @@
+package reader
+
+// lineState is the line being edited and where the cursor was in it.
+type lineState struct {
+	text   string
+	cursor int
+}
+
+func (r *StreamReader) lineState() lineState {
+	return lineState{string(r.buffer), r.cursor}
+}
+
+// saveUndo remembers the line as it is now, for undo to go back to.
+func (r *StreamReader) saveUndo() {
+	r.pushUndo(r.lineState())
+}
+
+// pushUndo adds state to the undo stack. A new change leaves nothing to
+// redo.
+func (r *StreamReader) pushUndo(state lineState) {
+	r.redo = r.redo[:0]
+	if n := len(r.undo); n > 0 && r.undo[n-1].text == state.text {
+		return
+	}
+	r.undo = append(r.undo, state)
+}
+
+// trackChange saves the line as it was before the key just handled, if
+// that changed it. Characters typed in a row make up one change, so that
+// undo takes them back together.
+func (r *StreamReader) trackChange(before lineState) {
+	if r.lastAction == actionUndo || string(r.buffer) == before.text {
+		return
+	}
+
+	switch {
+	case r.options.Vi:
+		// Vi saves the line as each insert starts, apart from the first
+		if len(r.undo) > 0 {
+			return
+		}
+	case r.lastAction == actionInsert && r.previousAction == actionInsert:
+		return
+	}
+	r.pushUndo(before)
+}
+
+// undoChange puts the line back the way it was before the last change.
+func (r *StreamReader) undoChange() {
+	r.lastAction = actionUndo
+	if len(r.undo) == 0 {
+		r.ringBell()
+		return
+	}
+
+	state := r.undo[len(r.undo)-1]
+	r.undo = r.undo[:len(r.undo)-1]
+	r.redo = append(r.redo, r.lineState())
+	r.setLine(state.text, state.cursor)
+}
+
+// redoChange makes again the change undone last.
+func (r *StreamReader) redoChange() {
+	r.lastAction = actionUndo
+	if len(r.redo) == 0 {
+		r.ringBell()
+		return
+	}
+
+	state := r.redo[len(r.redo)-1]
+	r.redo = r.redo[:len(r.redo)-1]
+	r.undo = append(r.undo, r.lineState())
+	r.setLine(state.text, state.cursor)
+}
@@ -0,0 +1,487 @@
+package reader
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// viState is where vi mode editing is at. Each line starts in insert mode,
+// where keys work as they do in emacs mode, and Escape switches to command
+// mode.
+type viState struct {
+	// command is set in command mode
+	command bool
+	// register holds the text deleted or yanked last, for p and P
+	register string
+	// findKey and findChar are the last f, F, t or T search, for ; and ,
+	findKey  byte
+	findChar rune
+	// lastChange holds the keys of the last change, for .
+	lastChange []byte
+}
+
+// enterCommandMode switches to command mode, stepping back onto the last
+// character typed.
+func (r *StreamReader) enterCommandMode() {
+	r.vi.command = true
+
+	// The Escape ends the change that entered insert mode
+	if r.recording != nil {
+		r.vi.lastChange = r.recording
+		r.recording = nil
+	}
+
+	r.moveCursor(r.prevPos(r.cursor))
+}
+
+// viCommand runs the command mode command that starts with key, recording
+// the keys of changes for the . command to repeat.
+func (r *StreamReader) viCommand(key byte) error {
+	r.recording = []byte{key}
+
+	change, err := r.runViCommand(key)
+	if err != nil || !change {
+		r.recording = nil
+		return err
+	}
+
+	// A change that went into insert mode is recorded up to the Escape
+	if r.vi.command {
+		r.vi.lastChange = r.recording
+		r.recording = nil
+	}
+	return nil
+}
+
+// runViCommand runs a command with an optional count and reports whether
+// it changed the line.
+func (r *StreamReader) runViCommand(key byte) (bool, error) {
+	count, key, err := r.readCount(key)
+	if err != nil {
+		return false, err
+	}
+	n := max(count, 1)
+
+	switch key {
+	case 'i':
+		r.viInsert(r.cursor)
+	case 'a':
+		r.viInsert(r.nextPos(r.cursor))
+	case 'I':
+		r.viInsert(r.firstNonBlank())
+	case 'A':
+		r.viInsert(len(r.buffer))
+	case 'x':
+		return r.viOperate('d', r.cursor, r.repeatPos(r.nextPos, r.cursor, n)), nil
+	case 'X':
+		return r.viOperate('d', r.repeatPos(r.prevPos, r.cursor, n), r.cursor), nil
+	case 's':
+		r.viOperate('c', r.cursor, r.repeatPos(r.nextPos, r.cursor, n))
+	case 'S':
+		r.viOperate('c', 0, len(r.buffer))
+	case 'D':
+		return r.viOperate('d', r.cursor, len(r.buffer)), nil
+	case 'C':
+		r.viOperate('c', r.cursor, len(r.buffer))
+	case 'd', 'c', 'y':
+		return r.viOperator(key, n)
+	case 'p', 'P':
+		return r.viPut(key == 'p', n), nil
+	case 'r':
+		return r.viReplace(n)
+	case '~':
+		return r.viToggleCase(n), nil
+	case 'u':
+		r.undoChange()
+		r.clampCursor()
+		return false, nil
+	case '.':
+		r.viRepeat(count)
+		return false, nil
+	case 'k', '-':
+		r.historyUp()
+		r.moveCursor(0)
+		return false, nil
+	case 'j', '+':
+		r.historyDown()
+		r.moveCursor(0)
+		return false, nil
+	default:
+		pos, _, ok, err := r.viMotion(key, n)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			r.ringBell()
+			return false, nil
+		}
+		r.moveCursor(min(pos, r.lastPos()))
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// readCount reads the digits of a count that starts with key, returning
+// the count, or 0 for none, and the key after it.
+func (r *StreamReader) readCount(key byte) (int, byte, error) {
+	count := 0
+	for key >= '1' && key <= '9' || count > 0 && key == '0' {
+		count = count*10 + int(key-'0')
+
+		var err error
+		if key, err = r.readKey(); err != nil {
+			return 0, 0, err
+		}
+	}
+	return count, key, nil
+}
+
+// viOperator runs d, c or y on the text a motion moves over, or on the
+// whole line when the operator is doubled.
+func (r *StreamReader) viOperator(op byte, n int) (bool, error) {
+	key, err := r.readKey()
+	if err != nil {
+		return false, err
+	}
+	count, key, err := r.readCount(key)
+	if err != nil {
+		return false, err
+	}
+	n *= max(count, 1)
+
+	if key == op {
+		return r.viOperate(op, 0, len(r.buffer)), nil
+	}
+
+	// cw changes to the end of the word, like ce
+	if op == 'c' && (key == 'w' || key == 'W') && r.cursor < len(r.buffer) && !unicode.IsSpace(r.buffer[r.cursor]) {
+		key -= 'w' - 'e'
+	}
+
+	pos, inclusive, ok, err := r.viMotion(key, n)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		r.ringBell()
+		return false, nil
+	}
+
+	from, to := r.cursor, pos
+	if to < from {
+		from, to = to, from
+	} else if inclusive {
+		to = r.nextPos(to)
+	}
+
+	return r.viOperate(op, from, to), nil
+}
+
+// viOperate deletes, changes or yanks the text from from to to, keeping it
+// in the register. It reports whether the line changed.
+func (r *StreamReader) viOperate(op byte, from, to int) bool {
+	if from == to && op != 'c' {
+		r.ringBell()
+		return false
+	}
+
+	r.vi.register = string(r.buffer[from:to])
+
+	switch op {
+	case 'y':
+		r.moveCursor(from)
+		return false
+	case 'c':
+		r.saveUndo()
+		r.deleteRange(from, to)
+		r.vi.command = false
+	default:
+		r.saveUndo()
+		r.deleteRange(from, to)
+		r.clampCursor()
+	}
+	return true
+}
+
+// viInsert switches to insert mode with the cursor at pos.
+func (r *StreamReader) viInsert(pos int) {
+	r.saveUndo()
+	r.moveCursor(pos)
+	r.vi.command = false
+}
+
+// viPut inserts the register n times after or before the cursor and leaves
+// the cursor on the last character put in.
+func (r *StreamReader) viPut(after bool, n int) bool {
+	if r.vi.register == "" {
+		r.ringBell()
+		return false
+	}
+
+	r.saveUndo()
+	if after && len(r.buffer) > 0 {
+		r.moveCursor(r.nextPos(r.cursor))
+	}
+	r.insert(strings.Repeat(r.vi.register, n))
+	r.moveCursor(r.prevPos(r.cursor))
+	return true
+}
+
+// viReplace replaces n characters with the one typed next.
+func (r *StreamReader) viReplace(n int) (bool, error) {
+	key, err := r.readKey()
+	if err != nil {
+		return false, err
+	}
+	ch, ok, err := r.typedChar(key)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	if r.cursor+n > len(r.buffer) {
+		r.ringBell()
+		return false, nil
+	}
+
+	r.saveUndo()
+	line := string(r.buffer[:r.cursor]) + strings.Repeat(string(ch), n) + string(r.buffer[r.cursor+n:])
+	r.setLine(line, r.cursor+n-1)
+	return true, nil
+}
+
+// viToggleCase switches the case of n characters and moves past them.
+func (r *StreamReader) viToggleCase(n int) bool {
+	if len(r.buffer) == 0 {
+		r.ringBell()
+		return false
+	}
+
+	r.saveUndo()
+	line := append([]rune(nil), r.buffer...)
+	end := min(r.cursor+n, len(line))
+	for i := r.cursor; i < end; i++ {
+		if unicode.IsUpper(line[i]) {
+			line[i] = unicode.ToLower(line[i])
+		} else {
+			line[i] = unicode.ToUpper(line[i])
+		}
+	}
+	r.setLine(string(line), min(end, len(line)-1))
+	return true
+}
+
+// viRepeat replays the last change, with count in place of its own count
+// when one is given.
+func (r *StreamReader) viRepeat(count int) {
+	keys := r.vi.lastChange
+	if keys == nil {
+		r.ringBell()
+		return
+	}
+
+	if count > 0 {
+		keys = []byte(strings.TrimLeft(string(keys), "0123456789"))
+		keys = append([]byte(strconv.Itoa(count)), keys...)
+	}
+	r.replay = append(append([]byte(nil), keys...), r.replay...)
+}
+
+// viMotion works out where a motion moves the cursor, n times over. It
+// reports whether an operator takes in the character at the new position
+// too, and false when the motion goes nowhere.
+func (r *StreamReader) viMotion(key byte, n int) (pos int, inclusive bool, ok bool, err error) {
+	pos = r.cursor
+
+	switch key {
+	case 'h', KEY_BACKSPACE:
+		pos = r.repeatPos(r.prevPos, pos, n)
+	case 'l', ' ':
+		pos = r.repeatPos(r.nextPos, pos, n)
+	case '0':
+		pos = 0
+	case '^':
+		pos = r.firstNonBlank()
+	case '$':
+		return r.lastPos(), true, true, nil
+	case 'w', 'W':
+		for i := 0; i < n; i++ {
+			pos = r.viWordForward(pos, key == 'W')
+		}
+	case 'b', 'B':
+		for i := 0; i < n; i++ {
+			pos = r.viWordBackward(pos, key == 'B')
+		}
+	case 'e', 'E':
+		for i := 0; i < n; i++ {
+			pos = r.viWordEnd(pos, key == 'E')
+		}
+		return pos, true, true, nil
+	case 'f', 'F', 't', 'T':
+		next, err := r.readKey()
+		if err != nil {
+			return 0, false, false, err
+		}
+		ch, ok, err := r.typedChar(next)
+		if err != nil || !ok {
+			return 0, false, false, err
+		}
+		r.vi.findKey, r.vi.findChar = key, ch
+		pos, inclusive, ok = r.viFind(key, ch, n)
+		return pos, inclusive, ok, nil
+	case ';', ',':
+		find := r.vi.findKey
+		if find == 0 {
+			return 0, false, false, nil
+		}
+		if key == ',' {
+			// The other direction: f and F, t and T differ in case
+			find ^= 'a' - 'A'
+		}
+		pos, inclusive, ok = r.viFind(find, r.vi.findChar, n)
+		return pos, inclusive, ok, nil
+	default:
+		if key >= 0x80 {
+			// Skip the rest of a character that is no command
+			_, _, err = r.typedChar(key)
+		}
+		return 0, false, false, err
+	}
+
+	return pos, false, true, nil
+}
+
+// viFind finds the nth ch after the cursor for f and t, or before it for F
+// and T. t and T stop next to it.
+func (r *StreamReader) viFind(key byte, ch rune, n int) (int, bool, bool) {
+	forward := key == 'f' || key == 't'
+
+	pos := r.cursor
+	for found := 0; found < n; {
+		if forward {
+			pos++
+		} else {
+			pos--
+		}
+		if pos < 0 || pos >= len(r.buffer) {
+			return 0, false, false
+		}
+		if r.buffer[pos] == ch {
+			found++
+		}
+	}
+
+	switch key {
+	case 't':
+		pos--
+	case 'T':
+		pos++
+	}
+	return pos, forward, true
+}
+
+// viClass sorts characters for word motions: blanks, word characters and
+// punctuation, or blanks and the rest for the blank-separated WORDs.
+func viClass(ch rune, bigWord bool) int {
+	switch {
+	case unicode.IsSpace(ch):
+		return 0
+	case bigWord || isWordChar(ch):
+		return 1
+	}
+	return 2
+}
+
+// viWordForward is where the word after the one at pos starts.
+func (r *StreamReader) viWordForward(pos int, bigWord bool) int {
+	line := r.buffer
+	if pos >= len(line) {
+		return len(line)
+	}
+
+	class := viClass(line[pos], bigWord)
+	for pos < len(line) && class != 0 && viClass(line[pos], bigWord) == class {
+		pos++
+	}
+	for pos < len(line) && viClass(line[pos], bigWord) == 0 {
+		pos++
+	}
+	return pos
+}
+
+// viWordBackward is where the word before pos starts.
+func (r *StreamReader) viWordBackward(pos int, bigWord bool) int {
+	line := r.buffer
+	for pos > 0 && viClass(line[pos-1], bigWord) == 0 {
+		pos--
+	}
+	if pos == 0 {
+		return 0
+	}
+
+	class := viClass(line[pos-1], bigWord)
+	for pos > 0 && viClass(line[pos-1], bigWord) == class {
+		pos--
+	}
+	return pos
+}
+
+// viWordEnd is where the word after pos ends.
+func (r *StreamReader) viWordEnd(pos int, bigWord bool) int {
+	line := r.buffer
+	pos++
+	for pos < len(line) && viClass(line[pos], bigWord) == 0 {
+		pos++
+	}
+	if pos >= len(line) {
+		return r.lastPos()
+	}
+
+	class := viClass(line[pos], bigWord)
+	for pos+1 < len(line) && viClass(line[pos+1], bigWord) == class {
+		pos++
+	}
+	return pos
+}
+
+// repeatPos applies step to pos n times.
+func (r *StreamReader) repeatPos(step func(int) int, pos, n int) int {
+	for i := 0; i < n; i++ {
+		pos = step(pos)
+	}
+	return pos
+}
+
+// firstNonBlank is where the first character that is not a blank is.
+func (r *StreamReader) firstNonBlank() int {
+	for i, ch := range r.buffer {
+		if !unicode.IsSpace(ch) {
+			return i
+		}
+	}
+	return len(r.buffer)
+}
+
+// lastPos is where the last character of the line is, which is as far as
+// the cursor goes in command mode.
+func (r *StreamReader) lastPos() int {
+	if len(r.buffer) == 0 {
+		return 0
+	}
+	return r.prevPos(len(r.buffer))
+}
+
+// clampCursor keeps the cursor on a character in command mode.
+func (r *StreamReader) clampCursor() {
+	if r.vi.command && r.cursor > r.lastPos() {
+		r.moveCursor(r.lastPos())
+	}
+}
+
+// record adds a key read to the recording of the change being made.
+func (r *StreamReader) record(key byte) {
+	if r.recording != nil {
+		r.recording = append(r.recording, key)
+	}
+}
@@ -0,0 +1,65 @@
+package reader
+
+import "testing"
+
+func TestViCommands(t *testing.T) {
+	tests := []struct {
+		name   string
+		keys   string
+		line   string
+		cursor int
+	}{
+		{"cw", "one two three\x1b0cwON\x1b", "ON two three", 1},
+		{"cw from mid word", "one two three\x1b0lcwX\x1b", "oX two three", 1},
+		{"c2w", "one two three\x1b0c2wX\x1b", "X three", 0},
+		{"dw", "one two three\x1b0dw", "two three", 0},
+		{"dw at last word", "one two\x1b0wdw", "one ", 3},
+		{"2dw", "one two three\x1b02dw", "three", 0},
+		{"d$", "one two three\x1b0wd$", "one ", 3},
+		{"D", "one two three\x1b0wD", "one ", 3},
+		{"x", "abcdef\x1b0x", "bcdef", 0},
+		{"3x", "abcdef\x1b03x", "def", 0},
+		{"3x past the end", "abcdef\x1b$h3x", "abcd", 3},
+
+		{"f", "a-b-c-d\x1b0f-x", "ab-c-d", 1},
+		{"2f", "a-b-c-d\x1b02f-x", "a-bc-d", 3},
+		{"f;", "a-b-c-d\x1b0f-;x", "a-bc-d", 3},
+		{"f;,", "a-b-c-d\x1b0f-;;,x", "a-bc-d", 3},
+		{"F;", "a-b-c-d\x1b$F-;x", "a-bc-d", 3},
+		{"t", "abcabc\x1b0tcx", "acabc", 1},
+		{"t;", "abcabc\x1b0tcl;x", "abcac", 4},
+		{"t;,", "abcabc\x1b0tcl;,x", "abcbc", 3},
+		{"T", "abcabc\x1b$Tax", "abcac", 4},
+		{"dt", "one two\x1b0dtw", "wo", 0},
+		{"df", "one two\x1b0dfw", "o", 0},
+
+		{".", "one two three\x1b0dw.", "three", 0},
+		{". with count", "a b c d e f\x1b0dw3.", "e f", 0},
+		{"x.", "abcdef\x1b02x.", "ef", 0},
+		{"x. with count", "abcdef\x1b02x3.", "f", 0},
+		{"cw.", "one two three\x1b0cwX\x1bw.", "X X three", 2},
+
+		{"u", "one two\x1b0dwu", "one two", 0},
+		{"u after two changes", "abc\x1bxxu", "ab", 1},
+		{"u of insert", "one\x1bAtwo\x1bu", "one", 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newTestReader(t)
+			r.options.Vi = true
+			r.replay = []byte(test.keys + "\r")
+
+			line, err := r.readLine()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if line != test.line {
+				t.Errorf("line = %q, want %q", line, test.line)
+			}
+			if r.cursor != test.cursor {
+				t.Errorf("cursor = %d, want %d", r.cursor, test.cursor)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package reader
+
+import "unicode"
+
+// wideRanges are the East Asian wide and fullwidth characters, which take up
+// two columns of the terminal.
+var wideRanges = []struct{ first, last rune }{
+	{0x1100, 0x115f},   // Hangul Jamo
+	{0x231a, 0x231b},   // watch, hourglass
+	{0x2329, 0x232a},   // angle brackets
+	{0x23e9, 0x23ec},   // media controls
+	{0x2e80, 0x303e},   // CJK radicals to CJK symbols and punctuation
+	{0x3041, 0x33ff},   // Hiragana to CJK compatibility
+	{0x3400, 0x4dbf},   // CJK extension A
+	{0x4e00, 0x9fff},   // CJK unified ideographs
+	{0xa000, 0xa4cf},   // Yi
+	{0xa960, 0xa97f},   // Hangul Jamo extended A
+	{0xac00, 0xd7a3},   // Hangul syllables
+	{0xf900, 0xfaff},   // CJK compatibility ideographs
+	{0xfe10, 0xfe19},   // vertical forms
+	{0xfe30, 0xfe6f},   // CJK compatibility forms, small form variants
+	{0xff00, 0xff60},   // fullwidth forms
+	{0xffe0, 0xffe6},   // fullwidth signs
+	{0x16fe0, 0x18aff}, // Tangut
+	{0x1b000, 0x1b2ff}, // Kana supplement to Nushu
+	{0x1f004, 0x1f004}, // mahjong tile
+	{0x1f0cf, 0x1f0cf}, // playing card
+	{0x1f18e, 0x1f18e}, // squared AB
+	{0x1f191, 0x1f19a}, // squared words
+	{0x1f200, 0x1f251}, // enclosed ideographic supplement
+	{0x1f300, 0x1f64f}, // pictographs and emoticons
+	{0x1f680, 0x1f6ff}, // transport and map symbols
+	{0x1f900, 0x1f9ff}, // supplemental symbols and pictographs
+	{0x1fa70, 0x1faff}, // symbols and pictographs extended A
+	{0x20000, 0x2fffd}, // CJK extension B and later
+	{0x30000, 0x3fffd}, // CJK extension G and later
+}
+
+// runeWidth is how many terminal columns ch takes up: none for combining
+// marks and other zero-width characters, two for wide characters and one
+// otherwise.
+func runeWidth(ch rune) int {
+	if unicode.In(ch, unicode.Mn, unicode.Me, unicode.Cf, unicode.Cc) {
+		return 0
+	}
+
+	for _, r := range wideRanges {
+		if ch < r.first {
+			break
+		}
+		if ch <= r.last {
+			return 2
+		}
+	}
+
+	return 1
+}
+
+// width is how many terminal columns text takes up.
+func width(text []rune) int {
+	total := 0
+	for _, ch := range text {
+		total += runeWidth(ch)
+	}
+	return total
+}
+
+// backUp moves the terminal cursor back over text.
+func backUp(text []rune) string {
+	n := width(text)
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = '\b'
+	}
+	return string(b)
+}
@@ -0,0 +1,112 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/codecrafters-io/shell-starter-go/app/cmds"
+	"github.com/codecrafters-io/shell-starter-go/app/internal/expand"
+	"github.com/codecrafters-io/shell-starter-go/app/internal/jobs"
+	"github.com/codecrafters-io/shell-starter-go/app/internal/output"
+	"github.com/codecrafters-io/shell-starter-go/app/internal/reader"
+)
+
+// runForeground runs cmdPipe as a new job that owns the terminal and waits
+// for it to finish. A job stopped with Ctrl-Z goes to the job table.
+func runForeground(repl *cmds.Repl, cmdPipe *reader.CmdsPipe) (int, error) {
+	job := jobs.NewJob(cmdPipe.String())
+	job.SetForeground(true)
+
+	var err error
+	go func() {
+		status, runErr := runInJob(repl, cmdPipe, job)
+		err = runErr
+		job.Finish(status)
+	}()
+
+	if repl.Jobs.Foreground(job) == jobs.Stopped {
+		repl.Jobs.Add(job)
+		fmt.Fprintf(os.Stderr, "\n%s\n", repl.Jobs.Report(job))
+		return 128 + int(syscall.SIGTSTP), nil
+	}
+
+	return job.Status(), err
+}
+
+// runBackground starts an and-or list as a job and returns without waiting
+// for it. A list that runs builtins or assigns variables runs in a subshell,
+// so that it cannot change the shell's directory, variables, history or
+// jobs; other lists only start processes and run in the shell. Like other shells, only an interactive
+// one reports the job it started.
+func runBackground(repl *cmds.Repl, andOr *reader.AndOr) {
+	job := jobs.NewJob(andOr.String())
+	repl.Jobs.Add(job)
+
+	jobRepl := cloneRepl(repl)
+	go func() {
+		if runsBuiltin(andOr) {
+			job.Finish(runSubshell(jobRepl, andOr, job))
+		} else {
+			job.Finish(runAndOr(jobRepl, andOr, job))
+		}
+	}()
+
+	pgid := job.WaitStarted()
+	if !repl.Jobs.Control {
+		return
+	}
+	if pgid != 0 {
+		fmt.Fprintf(os.Stderr, "[%d] %d\n", job.ID, pgid)
+	} else {
+		fmt.Fprintf(os.Stderr, "[%d]\n", job.ID)
+	}
+}
+
+// runsBuiltin reports whether a command of andOr is a builtin or starts
+// with an assignment.
+func runsBuiltin(andOr *reader.AndOr) bool {
+	for _, item := range andOr.Items {
+		for _, cmd := range item.Pipe.Cmds {
+			if len(cmd.Words) > 0 && (isBuiltinCommandV2(cmd.Words[0]) || expand.IsAssignment(cmd.Words[0])) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// subshell returns the program and arguments that run script in a new shell
+// process, given the same variables and positional parameters.
+func subshell(repl *cmds.Repl, script string) (string, []string) {
+	shell, err := os.Executable()
+	if err != nil {
+		shell = os.Args[0]
+	}
+
+	// Exported variables are inherited, the others are declared first
+	script = repl.Declarations() + script
+
+	return shell, append([]string{"-c", script}, repl.Positional()...)
+}
+
+// runSubshell runs andOr as part of job in a new shell process and returns
+// its exit status.
+func runSubshell(repl *cmds.Repl, andOr *reader.AndOr, job *jobs.Job) int {
+	shell, args := subshell(repl, andOr.Script())
+
+	table := output.NewFdTable(repl.GetInput(), repl.GetOutput(), repl.GetErrorOutput())
+	defer table.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	job.SetCancel(cancel)
+
+	group := &processGroup{job: job, control: repl.Jobs.Control}
+	status, err := runProcess(ctx, group, shell, args, nil, table)
+	if err != nil {
+		repl.PrintError(err.Error())
+	}
+	return status
+}
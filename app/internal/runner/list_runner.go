@@ -0,0 +1,72 @@
+package runner
+
+import (
+	"fmt"
+	"syscall"
+
+	"github.com/codecrafters-io/shell-starter-go/app/cmds"
+	"github.com/codecrafters-io/shell-starter-go/app/internal/jobs"
+	"github.com/codecrafters-io/shell-starter-go/app/internal/reader"
+)
+
+// statusInterrupted is the status of a command killed by Ctrl-C. Like other
+// shells, the rest of the list is abandoned after it.
+const statusInterrupted = 128 + int(syscall.SIGINT)
+
+// RunCmdList runs the and-or lists of a list in order, starting the ones
+// terminated by & as background jobs.
+func RunCmdList(repl *cmds.Repl, list *reader.CmdList) int {
+	status := 0
+
+	for _, andOr := range list.AndOrs {
+		if andOr.Background {
+			runBackground(repl, andOr)
+			status = 0
+			repl.SetLastStatus(status)
+			continue
+		}
+
+		status = runAndOr(repl, andOr, nil)
+		if status == statusInterrupted {
+			// The terminal echoed ^C without a newline
+			if repl.Jobs.Control {
+				fmt.Println()
+			}
+			break
+		}
+	}
+
+	return status
+}
+
+// runAndOr runs the pipelines of an and-or list as part of job, or each in
+// the foreground when job is nil. A pipeline joined with && only runs if the
+// previous status is zero, one joined with || only if it is non-zero; a
+// skipped pipeline leaves the status unchanged.
+func runAndOr(repl *cmds.Repl, andOr *reader.AndOr, job *jobs.Job) int {
+	status := 0
+
+	for _, item := range andOr.Items {
+		if item.Op == reader.OpAnd && status != 0 {
+			continue
+		}
+
+		if item.Op == reader.OpOr && status == 0 {
+			continue
+		}
+
+		var err error
+		status, err = runPipe(repl, item.Pipe, job)
+		if err != nil {
+			repl.PrintError(err.Error())
+		}
+
+		repl.SetLastStatus(status)
+
+		if status == statusInterrupted {
+			break
+		}
+	}
+
+	return status
+}
@@ -1,50 +1,46 @@
 package runner
 
 import (
-	"bytes"
 	"context"
 	"fmt"
-	"io"
 	"os"
-	"os/exec"
+	"strings"
 	"sync"
-	"syscall"
-	"time"
 
 	"github.com/codecrafters-io/shell-starter-go/app/cmds"
+	"github.com/codecrafters-io/shell-starter-go/app/internal/expand"
+	"github.com/codecrafters-io/shell-starter-go/app/internal/jobs"
 	"github.com/codecrafters-io/shell-starter-go/app/internal/output"
 	"github.com/codecrafters-io/shell-starter-go/app/internal/reader"
 )
 
-type OutputStreamWriter struct {
-	output output.Output
-}
-
-func (osw *OutputStreamWriter) Write(p []byte) (n int, err error) {
-	osw.output.WriteStream(bytes.NewReader(p))
-	return len(p), nil
-}
-
 type PipeRunner struct {
 	repl     *cmds.Repl
 	commands []*reader.Cmd
 	ctx      context.Context
 	cancel   context.CancelFunc
-	pipes    []*io.PipeReader
-	writers  []*io.PipeWriter
+	pipes    []*os.File
+	writers  []*os.File
 	wg       sync.WaitGroup
 	errChan  chan error
+	statuses []int
+	group    *processGroup
 }
 
-func NewPipeRunner(repl *cmds.Repl, cmdPipe *reader.CmdsPipe) *PipeRunner {
+func NewPipeRunner(repl *cmds.Repl, cmdPipe *reader.CmdsPipe, job *jobs.Job) (*PipeRunner, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	numPipes := len(cmdPipe.Cmds) - 1
-	pipes := make([]*io.PipeReader, numPipes)
-	writers := make([]*io.PipeWriter, numPipes)
+	pipes := make([]*os.File, numPipes)
+	writers := make([]*os.File, numPipes)
 
 	for i := 0; i < numPipes; i++ {
-		pipes[i], writers[i] = io.Pipe()
+		var err error
+		pipes[i], writers[i], err = os.Pipe()
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to create pipe: %v", err)
+		}
 	}
 
 	return &PipeRunner{
@@ -55,52 +51,77 @@ func NewPipeRunner(repl *cmds.Repl, cmdPipe *reader.CmdsPipe) *PipeRunner {
 		pipes:    pipes,
 		writers:  writers,
 		errChan:  make(chan error, len(cmdPipe.Cmds)),
-	}
+		statuses: make([]int, len(cmdPipe.Cmds)),
+		group:    &processGroup{job: job, control: repl.Jobs.Control},
+	}, nil
+}
+
+// RunPipeCmdsV2 runs a pipeline in the foreground and returns the exit
+// status of its last stage.
+func RunPipeCmdsV2(repl *cmds.Repl, cmdPipe *reader.CmdsPipe) (int, error) {
+	return runPipe(repl, cmdPipe, nil)
 }
 
-func RunPipeCmdsV2(repl *cmds.Repl, cmdPipe *reader.CmdsPipe) error {
+// runPipe runs a pipeline as part of job, or as a new foreground job when
+// job is nil. A lone builtin always runs in the shell itself.
+func runPipe(repl *cmds.Repl, cmdPipe *reader.CmdsPipe, job *jobs.Job) (int, error) {
 	if cmdPipe == nil {
-		return ErrInvalidCommand
+		return StatusForError(ErrInvalidCommand), ErrInvalidCommand
 	}
 
 	if len(cmdPipe.Cmds) == 0 {
-		return ErrEmptyCommand
+		return StatusForError(ErrEmptyCommand), ErrEmptyCommand
 	}
 
 	if len(cmdPipe.Cmds) == 1 {
-		return RunSingleCmd(repl, cmdPipe.Cmds[0])
+		return runSingleCmd(repl, cmdPipe.Cmds[0], job)
 	}
 
-	runner := NewPipeRunner(repl, cmdPipe)
+	return runInJob(repl, cmdPipe, job)
+}
+
+// runInJob runs every stage of cmdPipe in a fresh process group of job.
+func runInJob(repl *cmds.Repl, cmdPipe *reader.CmdsPipe, job *jobs.Job) (int, error) {
+	if job == nil {
+		return runForeground(repl, cmdPipe)
+	}
+
+	runner, err := NewPipeRunner(repl, cmdPipe, job)
+	if err != nil {
+		return 1, err
+	}
 	defer runner.cleanup()
 
+	job.SetPgid(0)
+	job.SetCancel(runner.cancel)
 	return runner.execute()
 }
 
-func (pr *PipeRunner) execute() error {
+func (pr *PipeRunner) execute() (int, error) {
 	for i, cmd := range pr.commands {
 		pr.wg.Add(1)
 		go pr.runCommand(i, cmd)
 	}
 
-	// Monitor for completion and handle cleanup
-	go pr.monitor()
-
 	pr.wg.Wait()
 	close(pr.errChan)
 
+	// The pipeline reports the status of its last stage
+	status := pr.statuses[len(pr.statuses)-1]
+
 	// Return first error if any
 	for err := range pr.errChan {
 		if err != nil {
-			return err
+			return status, err
 		}
 	}
 
-	return nil
+	return status, nil
 }
 
 func (pr *PipeRunner) runCommand(index int, cmd *reader.Cmd) {
 	defer pr.wg.Done()
+	defer pr.closeStagePipes(index)
 
 	select {
 	case <-pr.ctx.Done():
@@ -108,12 +129,8 @@ func (pr *PipeRunner) runCommand(index int, cmd *reader.Cmd) {
 	default:
 	}
 
-	var err error
-	if isBuiltinCommandV2(cmd.Command) {
-		err = pr.runBuiltinCommand(index, cmd)
-	} else {
-		err = pr.runExternalCommand(index, cmd)
-	}
+	status, err := pr.runStage(index, cmd)
+	pr.statuses[index] = status
 
 	if err != nil {
 		pr.errChan <- err
@@ -121,134 +138,109 @@ func (pr *PipeRunner) runCommand(index int, cmd *reader.Cmd) {
 	}
 }
 
-func (pr *PipeRunner) runBuiltinCommand(index int, cmd *reader.Cmd) error {
-	var cmdOutput output.Output = pr.repl.GetOutput()
+func (pr *PipeRunner) runStage(index int, cmd *reader.Cmd) (int, error) {
+	cmd, err := cmd.Expand(pr.repl.LookupVar)
+	if err != nil {
+		return 1, err
+	}
+
+	// Stages read from the previous pipe and write to the next one, then
+	// their own redirections are applied on top
+	stdin := pr.repl.GetInput()
+	if index > 0 {
+		stdin = pr.pipes[index-1]
+	}
 
-	// If not the last command, redirect to pipe
+	stdout := pr.repl.GetOutput()
 	if index < len(pr.writers) {
-		cmdOutput = &output.PipeOutput{Writer: pr.writers[index]}
-		defer pr.writers[index].Close()
+		stdout = &output.PipeOutput{Writer: pr.writers[index]}
 	}
 
-	// If not the first command, consume input from previous pipe to prevent hanging
-	if index > 0 {
-		go func() {
-			io.Copy(io.Discard, pr.pipes[index-1])
-		}()
+	table, err := openFds(cmd, stdin, stdout, pr.repl.GetErrorOutput())
+	if err != nil {
+		return 1, err
+	}
+	defer table.Close()
+
+	// Assignments alone only last as long as the stage
+	if cmd.Command == "" {
+		return 0, nil
+	}
+
+	if isBuiltinCommandV2(cmd.Command) {
+		return pr.runBuiltinCommand(cmd, table)
+	}
+
+	return pr.runExternalCommand(cmd, table)
+}
+
+func (pr *PipeRunner) runBuiltinCommand(cmd *reader.Cmd, table *output.FdTable) (status int, err error) {
+	switch cmd.Command {
+	case "cd", "source", ".", "export":
+		// These change the shell itself, so they run apart from it
+		return pr.runSubshellStage(cmd, table)
 	}
 
-	// Create modified repl for this command
-	cmdRepl := pr.createCommandRepl(cmdOutput)
+	// Create modified repl for this command. Its variables are its own, so
+	// the assignments need no undoing.
+	cmdRepl := pr.createCommandRepl(table)
+	cmdRepl.ShadowVars(cmd.Assignments)
+	defer func() { status = checkWrites(cmdRepl, cmd.Command, status) }()
 
 	switch cmd.Command {
 	case "echo":
-		cmds.Echo(cmdRepl, cmd.Args)
-	case "history":
-		cmdRepl.History.Run(cmd.Args)
-	case "type":
+		return cmds.Echo(cmdRepl, cmd.Args), nil
+	case "type", "history", "set", "bind":
 		exe := cmds.NewCmd(cmdRepl, cmd.Command)
-		exe.Run(cmd.Args)
+		return exe.Run(cmd.Args), nil
 	case "pwd":
-		cmdRepl.Pwd()
-	case "cd":
-		if len(cmd.Args) > 0 {
-			cmdRepl.Cd(cmd.Args[0])
-		}
+		return cmdRepl.Pwd(), nil
 	case "exit":
-		cmdRepl.History.Close()
-		os.Exit(0)
+		// A pipeline stage runs apart from the shell, so exit only ends the stage
+		status, _ := cmds.ExitArgs(cmdRepl, cmd.Args)
+		return status, nil
+	case "jobs", "fg", "bg", "wait":
+		exe := cmds.NewCmd(cmdRepl, cmd.Command)
+		return exe.Run(cmd.Args), nil
 	}
 
-	return nil
+	return 0, nil
 }
 
-func (pr *PipeRunner) runExternalCommand(index int, cmd *reader.Cmd) error {
+func (pr *PipeRunner) runExternalCommand(cmd *reader.Cmd, table *output.FdTable) (int, error) {
 	_, ok := pr.repl.CmdExist(cmd.Command)
 	if !ok {
-		return fmt.Errorf("%s: command not found", cmd.Command)
-	}
-
-	execCmd := exec.CommandContext(pr.ctx, cmd.Command, cmd.Args...)
-
-	if index > 0 {
-		execCmd.Stdin = pr.pipes[index-1]
+		return StatusForError(ErrCommandNotFound), fmt.Errorf("%s: %w", cmd.Command, ErrCommandNotFound)
 	}
 
-	if index < len(pr.writers) {
-		// Not the last command - pipe to next
-		execCmd.Stdout = pr.writers[index]
-		defer pr.writers[index].Close()
-	} else {
-		// Last command - create pipe to terminal
-		stdout, err := execCmd.StdoutPipe()
-		if err != nil {
-			return fmt.Errorf("failed to create stdout pipe: %v", err)
-		}
+	return runProcess(pr.ctx, pr.group, cmd.Command, cmd.Args, cmd.Assignments, table)
+}
 
-		go func() {
-			osw := &OutputStreamWriter{output: pr.repl.GetOutput()}
-			io.Copy(osw, stdout)
-		}()
+// runSubshellStage runs the expanded builtin cmd in a new shell process on
+// the stage's file descriptors, which already have its redirections.
+func (pr *PipeRunner) runSubshellStage(cmd *reader.Cmd, table *output.FdTable) (int, error) {
+	words := make([]string, 0, len(cmd.Assignments)+1+len(cmd.Args))
+	for _, assignment := range cmd.Assignments {
+		name, value, _ := strings.Cut(assignment, "=")
+		words = append(words, name+"="+expand.Quote(value))
 	}
-
-	// Set up stderr (always goes to terminal)
-	stderr, err := execCmd.StderrPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stderr pipe: %v", err)
+	for _, word := range append([]string{cmd.Command}, cmd.Args...) {
+		words = append(words, expand.Quote(word))
 	}
 
-	go func() {
-		pr.repl.GetErrorOutput().WriteStream(stderr)
-	}()
-
-	// Start and wait for command
-	if err := execCmd.Start(); err != nil {
-		return fmt.Errorf("failed to start command: %v", err)
-	}
-
-	done := make(chan error, 1)
-	go func() {
-		done <- execCmd.Wait()
-	}()
-
-	select {
-	case err := <-done:
-		if err != nil {
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
-					if status.Signal() == syscall.SIGPIPE {
-						return nil // SIGPIPE is normal
-					}
-				}
-			}
-			return err
-		}
-		return nil
-	case <-pr.ctx.Done():
-		if execCmd.Process != nil {
-			execCmd.Process.Kill()
-		}
-		<-done // Wait for process to actually exit
-		return nil
-	}
+	shell, args := subshell(pr.repl, strings.Join(words, " "))
+	return runProcess(pr.ctx, pr.group, shell, args, nil, table)
 }
 
-func (pr *PipeRunner) monitor() {
-	// Give commands a moment to start
-	time.Sleep(10 * time.Millisecond)
-
-	// Monitor for early completion
-	ticker := time.NewTicker(100 * time.Millisecond)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-pr.ctx.Done():
-			return
-		case <-ticker.C:
-			// Check if any downstream processes have exited
-			continue
-		}
+// closeStagePipes closes the shell's copies of the pipe ends a finished stage
+// used, so that the next stage sees EOF and the previous one gets SIGPIPE
+// instead of blocking forever.
+func (pr *PipeRunner) closeStagePipes(index int) {
+	if index > 0 {
+		pr.pipes[index-1].Close()
+	}
+	if index < len(pr.writers) {
+		pr.writers[index].Close()
 	}
 }
 
@@ -261,18 +253,26 @@ func (pr *PipeRunner) cleanup() {
 		}
 	}
 
-	// Give processes time to clean up
-	time.Sleep(50 * time.Millisecond)
+	for _, pipe := range pr.pipes {
+		if pipe != nil {
+			pipe.Close()
+		}
+	}
 }
 
-func (pr *PipeRunner) createCommandRepl(cmdOutput output.Output) *cmds.Repl {
-	newRepl := &cmds.Repl{}
-	*newRepl = *pr.repl
+func (pr *PipeRunner) createCommandRepl(table *output.FdTable) *cmds.Repl {
+	newRepl := cloneRepl(pr.repl)
 
-	newRepl.SetOutput(cmdOutput)
+	useFds(newRepl, table)
 	return newRepl
 }
 
+// cloneRepl copies repl so that redirections, variables and $? can change
+// on the copy without touching the original.
+func cloneRepl(repl *cmds.Repl) *cmds.Repl {
+	return repl.Copy()
+}
+
 func isBuiltinCommandV2(command string) bool {
 	builtins := map[string]bool{
 		"echo":    true,
@@ -281,6 +281,15 @@ func isBuiltinCommandV2(command string) bool {
 		"pwd":     true,
 		"cd":      true,
 		"exit":    true,
+		"jobs":    true,
+		"fg":      true,
+		"bg":      true,
+		"wait":    true,
+		"source":  true,
+		".":       true,
+		"set":     true,
+		"bind":    true,
+		"export":  true,
 	}
 	return builtins[command]
 }
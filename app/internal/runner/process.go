@@ -0,0 +1,279 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/codecrafters-io/shell-starter-go/app/cmds"
+	"github.com/codecrafters-io/shell-starter-go/app/internal/jobs"
+	"github.com/codecrafters-io/shell-starter-go/app/internal/output"
+	"golang.org/x/sys/unix"
+)
+
+// processGroup puts the processes of one pipeline of a job into a process
+// group of their own, led by whichever starts first.
+type processGroup struct {
+	mu      sync.Mutex
+	job     *jobs.Job
+	control bool
+}
+
+// start starts the program at path in the group with files as its file
+// descriptors, in env or the shell's own environment if nil. A job in the
+// foreground hands the terminal to the group from the child, before the
+// command runs.
+func (g *processGroup) start(path string, argv []string, env []string, files []*os.File) (*os.Process, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	attr := &os.ProcAttr{Env: env, Files: files}
+	if g.control {
+		attr.Sys = &syscall.SysProcAttr{
+			Setpgid:    true,
+			Pgid:       g.job.Pgid(),
+			Foreground: g.job.Foreground(),
+			Ctty:       jobs.TTY,
+		}
+	}
+
+	process, err := os.StartProcess(path, argv, attr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without job control the leader's pid is still what the job reports
+	if g.job.Pgid() == 0 {
+		g.job.SetPgid(process.Pid)
+	}
+
+	return process, nil
+}
+
+// waitid codes for a child that stopped or continued
+const (
+	cldStopped   = 5
+	cldContinued = 6
+)
+
+// watch reports on the job when the process stops or continues, until it
+// exits. The exit itself is left to exec.Cmd.Wait to reap.
+func (g *processGroup) watch(pid int) {
+	for {
+		var info unix.Siginfo
+		err := unix.Waitid(unix.P_PID, pid, &info, unix.WEXITED|unix.WSTOPPED|unix.WCONTINUED|unix.WNOWAIT, nil)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return
+		}
+
+		// Consume the stop or continue so the next call waits for a new one
+		switch info.Code {
+		case cldStopped:
+			unix.Waitid(unix.P_PID, pid, &info, unix.WSTOPPED|unix.WNOHANG, nil)
+			g.job.SetState(jobs.Stopped)
+		case cldContinued:
+			unix.Waitid(unix.P_PID, pid, &info, unix.WCONTINUED|unix.WNOHANG, nil)
+			g.job.SetState(jobs.Running)
+		default:
+			return
+		}
+	}
+}
+
+// runProcess runs an external command in group with its file descriptors
+// taken from table and the variables in assignments, NAME=value each, added
+// to its environment. It returns the exit status. The process is
+// interrupted when ctx is done.
+//
+// os/exec would give the child /dev/null for a closed stdin, stdout or
+// stderr, so the process is started directly and sees them closed instead.
+func runProcess(ctx context.Context, group *processGroup, name string, args []string, assignments []string, table *output.FdTable) (int, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return 126, fmt.Errorf("%s: failed to start command: %v", name, err)
+	}
+
+	files, fds, err := attachFds(table)
+	if err != nil {
+		return 1, fmt.Errorf("%s: %v", name, err)
+	}
+
+	env := environ(assignments)
+	process, err := group.start(path, append([]string{name}, args...), env, files)
+	if errors.Is(err, syscall.ENOEXEC) {
+		// Like other shells, run an executable without #! as a script
+		shell, argv := asScript(path, args)
+		process, err = group.start(shell, argv, env, files)
+	}
+	fds.started()
+	if err != nil {
+		return 126, fmt.Errorf("%s: failed to start command: %v", name, err)
+	}
+
+	// Cancelling the pipeline interrupts its processes like Ctrl-C would
+	stop := context.AfterFunc(ctx, func() {
+		process.Signal(os.Interrupt)
+	})
+	defer stop()
+
+	group.watch(process.Pid)
+
+	state, err := process.Wait()
+	fds.finished()
+	if err != nil {
+		return 1, err
+	}
+
+	if !state.Success() {
+		// A failing command (including one killed by SIGPIPE) only sets its status
+		return cmds.ExitStatus(&exec.ExitError{ProcessState: state}), nil
+	}
+
+	return 0, nil
+}
+
+// environ returns the shell's environment with assignments put in, or nil
+// when there are none.
+func environ(assignments []string) []string {
+	if len(assignments) == 0 {
+		return nil
+	}
+
+	env := os.Environ()
+	for _, assignment := range assignments {
+		name, _, _ := strings.Cut(assignment, "=")
+		env = slices.DeleteFunc(env, func(entry string) bool {
+			return strings.HasPrefix(entry, name+"=")
+		})
+		env = append(env, assignment)
+	}
+	return env
+}
+
+// asScript returns the program and arguments that run the file at path,
+// which failed to execute, with this shell instead.
+func asScript(path string, args []string) (string, []string) {
+	shell, err := os.Executable()
+	if err != nil {
+		shell = os.Args[0]
+	}
+
+	return shell, append([]string{shell, path}, args...)
+}
+
+// processFds connects a command's fd table to a child process. Files are
+// handed over as they are; any other reader or writer is served through an
+// OS pipe and a goroutine copying to or from it.
+type processFds struct {
+	files map[any]*os.File
+	// childEnds are the pipe ends only the child should keep open
+	childEnds []*os.File
+	copies    sync.WaitGroup
+}
+
+// attachFds returns the files a child process gets as its file descriptors
+// from table, with nil for those that are closed. Call started after the
+// process starts and finished after it is waited for.
+func attachFds(table *output.FdTable) ([]*os.File, *processFds, error) {
+	p := &processFds{files: make(map[any]*os.File)}
+
+	var fds []*os.File
+	for _, fd := range table.Fds() {
+		entry, _ := table.Get(fd)
+
+		var file *os.File
+		var err error
+		if entry.Output != nil {
+			file, err = p.outputFile(entry.Output)
+		} else if entry.Input != nil {
+			file, err = p.inputFile(entry.Input)
+		}
+		if err != nil {
+			p.started()
+			return nil, nil, err
+		}
+
+		for len(fds) <= fd {
+			fds = append(fds, nil)
+		}
+		fds[fd] = file
+	}
+
+	return fds, p, nil
+}
+
+func (p *processFds) outputFile(out output.Output) (*os.File, error) {
+	if file, ok := p.files[out]; ok {
+		return file, nil
+	}
+
+	if fileBacked, ok := out.(output.FileBacked); ok && fileBacked.File() != nil {
+		p.files[out] = fileBacked.File()
+		return fileBacked.File(), nil
+	}
+
+	pipeReader, pipeWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	p.copies.Add(1)
+	go func() {
+		defer p.copies.Done()
+		out.WriteStream(pipeReader)
+		pipeReader.Close()
+	}()
+
+	p.childEnds = append(p.childEnds, pipeWriter)
+	p.files[out] = pipeWriter
+	return pipeWriter, nil
+}
+
+func (p *processFds) inputFile(in io.Reader) (*os.File, error) {
+	if file, ok := in.(*os.File); ok {
+		return file, nil
+	}
+
+	if file, ok := p.files[in]; ok {
+		return file, nil
+	}
+
+	pipeReader, pipeWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	// Not waited for: the copy ends with EPIPE if the child stops reading
+	go func() {
+		io.Copy(pipeWriter, in)
+		pipeWriter.Close()
+	}()
+
+	p.childEnds = append(p.childEnds, pipeReader)
+	p.files[in] = pipeReader
+	return pipeReader, nil
+}
+
+// started closes the parent's copies of the pipe ends given to the child,
+// so that EOF reaches the copying goroutines when the child exits.
+func (p *processFds) started() {
+	for _, file := range p.childEnds {
+		file.Close()
+	}
+	p.childEnds = nil
+}
+
+// finished waits until everything the child wrote has been copied out.
+func (p *processFds) finished() {
+	p.copies.Wait()
+}
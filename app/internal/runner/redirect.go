@@ -0,0 +1,67 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/codecrafters-io/shell-starter-go/app/cmds"
+	"github.com/codecrafters-io/shell-starter-go/app/internal/output"
+	"github.com/codecrafters-io/shell-starter-go/app/internal/reader"
+)
+
+// openFds builds the fd table of an expanded command from its default
+// streams and its redirections. The table must be closed once the command
+// has finished.
+func openFds(cmd *reader.Cmd, stdin io.Reader, stdout, stderr output.Output) (*output.FdTable, error) {
+	table := output.NewFdTable(stdin, stdout, stderr)
+	if err := table.Apply(cmd.Redirections); err != nil {
+		table.Close()
+		return nil, err
+	}
+
+	return table, nil
+}
+
+// useFds points the standard streams of a builtin's repl at the fd table.
+// Output to a closed fd fails, which checkWrites reports.
+func useFds(repl *cmds.Repl, table *output.FdTable) {
+	repl.SetInput(table.Input(0))
+	repl.SetOutput(outputOrClosed(table.Output(1)))
+	repl.SetErrorOutput(outputOrClosed(table.Output(2)))
+}
+
+// saveFds remembers the standard streams of repl and returns a function
+// that puts them back once a builtin has run with its own.
+func saveFds(repl *cmds.Repl) func() {
+	input, out, errorOutput := repl.GetInput(), repl.GetOutput(), repl.GetErrorOutput()
+
+	return func() {
+		repl.SetInput(input)
+		repl.SetOutput(out)
+		repl.SetErrorOutput(errorOutput)
+	}
+}
+
+func outputOrClosed(out output.Output) output.Output {
+	if out == nil {
+		return &output.ClosedOutput{}
+	}
+	return out
+}
+
+// checkWrites returns the status of the builtin name, which is a failure
+// if it wrote to a closed fd.
+func checkWrites(repl *cmds.Repl, name string, status int) int {
+	failed := false
+	for _, out := range []output.Output{repl.GetOutput(), repl.GetErrorOutput()} {
+		if closed, ok := out.(*output.ClosedOutput); ok && closed.Failed() {
+			failed = true
+		}
+	}
+	if !failed {
+		return status
+	}
+
+	repl.PrintError(fmt.Sprintf("%s: write error: Bad file descriptor", name))
+	return 1
+}
@@ -0,0 +1,54 @@
+package runner
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/shell-starter-go/app/cmds"
+)
+
+func TestWriteToClosedFd(t *testing.T) {
+	t.Setenv("HISTFILE", filepath.Join(t.TempDir(), "history"))
+
+	tests := []struct {
+		script string
+		status int
+	}{
+		{"echo hi >&-", 1},
+		{"echo hi 2>&- >&-", 1},
+		{"cd . >&-", 0},
+		{"echo hi >&- | cat", 0},
+		{"true | echo hi >&-", 1},
+	}
+
+	for _, test := range tests {
+		repl := cmds.InitRepl()
+		if status := RunScript(repl, "test", strings.NewReader(test.script)); status != test.status {
+			t.Errorf("%s: status = %d, want %d", test.script, status, test.status)
+		}
+	}
+}
+
+func TestExternalClosedFd(t *testing.T) {
+	t.Setenv("HISTFILE", filepath.Join(t.TempDir(), "history"))
+
+	// The child sees the fd closed rather than reading or writing /dev/null
+	tests := []struct {
+		script string
+		status int
+	}{
+		{"ls / >&-", 2},
+		{"cat <&-", 1},
+		{"ls / >&- | cat", 0},
+		{"true | ls / >&-", 2},
+		{"cat <&- </dev/null", 0},
+	}
+
+	for _, test := range tests {
+		repl := cmds.InitRepl()
+		if status := RunScript(repl, "test", strings.NewReader(test.script)); status != test.status {
+			t.Errorf("%s: status = %d, want %d", test.script, status, test.status)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/codecrafters-io/shell-starter-go/app/cmds"
+	"github.com/codecrafters-io/shell-starter-go/app/internal/reader"
+)
+
+// RunScript runs the commands read from input in repl and returns the
+// status of the last one. A syntax error stops the script with status 2.
+func RunScript(repl *cmds.Repl, name string, input io.Reader) int {
+	scriptReader := reader.NewScriptReader(input)
+	status := 0
+
+	for {
+		cmdList, err := scriptReader.ReadCommand()
+		if errors.Is(err, io.EOF) {
+			return status
+		}
+		if errors.Is(err, reader.ErrSyntax) {
+			repl.PrintError(fmt.Sprintf("%s: line %d: %v", name, scriptReader.Line(), err))
+			return 2
+		}
+		if err != nil {
+			repl.PrintError(fmt.Sprintf("%s: %v", name, err))
+			return 1
+		}
+
+		if cmdList == nil {
+			continue
+		}
+
+		status = RunCmdList(repl, cmdList)
+	}
+}
+
+// source runs a file in the shell itself, so that it can change the working
+// directory and the rest of the repl's state. Extra arguments become the
+// positional parameters while it runs.
+func source(repl *cmds.Repl, name string, args []string) int {
+	if len(args) == 0 {
+		repl.PrintError(fmt.Sprintf("%s: filename argument required", name))
+		return 2
+	}
+
+	if len(args) > 1 {
+		saved := repl.Positional()
+		repl.SetPositional(saved[0], args[1:])
+		defer repl.SetPositional(saved[0], saved[1:])
+	}
+
+	status, err := SourceFile(repl, findSourceFile(args[0]))
+	if err != nil {
+		repl.PrintError(fmt.Sprintf("%s: %s: No such file or directory", name, args[0]))
+		return 1
+	}
+
+	return status
+}
+
+// SourceFile runs the file at path in the shell itself and returns the
+// status of its last command. The error is set when it cannot be opened.
+func SourceFile(repl *cmds.Repl, path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 1, err
+	}
+	defer file.Close()
+
+	return RunScript(repl, path, file), nil
+}
+
+// findSourceFile looks a file name without a slash up in PATH, falling
+// back to the current directory.
+func findSourceFile(name string) string {
+	if strings.Contains(name, "/") {
+		return name
+	}
+
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path
+		}
+	}
+
+	return name
+}
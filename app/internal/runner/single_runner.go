@@ -1,11 +1,12 @@
 package runner
 
 import (
+	"errors"
 	"fmt"
-	"os"
+	"strings"
 
 	"github.com/codecrafters-io/shell-starter-go/app/cmds"
-	"github.com/codecrafters-io/shell-starter-go/app/internal/output"
+	"github.com/codecrafters-io/shell-starter-go/app/internal/jobs"
 	"github.com/codecrafters-io/shell-starter-go/app/internal/reader"
 )
 
@@ -13,51 +14,80 @@ var ErrCommandNotFound = fmt.Errorf("command not found")
 var ErrInvalidCommand = fmt.Errorf("invalid command")
 var ErrEmptyCommand = fmt.Errorf("empty command")
 
-func RunSingleCmd(repl *cmds.Repl, cmdStruct *reader.Cmd) error {
+// RunSingleCmd runs one command in the foreground and returns its exit
+// status. A non-nil error means the command could not be run at all.
+func RunSingleCmd(repl *cmds.Repl, cmdStruct *reader.Cmd) (int, error) {
+	return runSingleCmd(repl, cmdStruct, nil)
+}
+
+// runSingleCmd runs a builtin in the shell process and anything else as a
+// one-stage pipeline of job.
+func runSingleCmd(repl *cmds.Repl, cmdStruct *reader.Cmd, job *jobs.Job) (status int, err error) {
 	if cmdStruct == nil {
-		return ErrInvalidCommand
+		return StatusForError(ErrInvalidCommand), ErrInvalidCommand
 	}
 
-	if cmdStruct.Command == "" {
-		return ErrEmptyCommand
+	expanded, err := cmdStruct.Expand(repl.LookupVar)
+	if err != nil {
+		return 1, err
 	}
 
-	args := cmdStruct.Args
-
-	redirectStdout, redirectStdErr, appendStdout, appendStdErr, fileName := output.ParseRedirectIfPresent(args)
+	if expanded.Command != "" && !isBuiltinCommandV2(expanded.Command) {
+		return runInJob(repl, &reader.CmdsPipe{Cmds: []*reader.Cmd{cmdStruct}}, job)
+	}
 
-	if redirectStdout || appendStdout {
-		repl.RedirectStdOutToFile(fileName, appendStdout)
-		args = args[0 : len(args)-2]
+	table, err := openFds(expanded, repl.GetInput(), repl.GetOutput(), repl.GetErrorOutput())
+	if err != nil {
+		return 1, err
 	}
+	defer table.Close()
 
-	if redirectStdErr || appendStdErr {
-		repl.RedirectStdErrToFile(fileName, appendStdErr)
-		args = args[0 : len(args)-2]
+	if expanded.Command == "" {
+		// Only redirections and assignments, or every word expanded to nothing
+		for _, assignment := range expanded.Assignments {
+			name, value, _ := strings.Cut(assignment, "=")
+			repl.SetVar(name, value)
+		}
+		return 0, nil
 	}
 
-	switch cmdStruct.Command {
+	args := expanded.Args
+
+	defer saveFds(repl)()
+	useFds(repl, table)
+	defer repl.ShadowVars(expanded.Assignments)()
+	defer func() { status = checkWrites(repl, expanded.Command, status) }()
+
+	switch expanded.Command {
 	case "echo":
-		cmds.Echo(repl, args)
-	case "history":
-		repl.History.Run(args)
-	case "type":
-		exe := cmds.NewCmd(repl, cmdStruct.Command)
-		exe.Run(args)
+		return cmds.Echo(repl, args), nil
+	case "type", "history", "jobs", "fg", "bg", "wait", "set", "bind", "export":
+		exe := cmds.NewCmd(repl, expanded.Command)
+		return exe.Run(args), nil
 	case "pwd":
-		repl.Pwd()
+		return repl.Pwd(), nil
 	case "cd":
-		repl.Cd(args[0])
+		return repl.Cd(args), nil
+	case "source", ".":
+		return source(repl, expanded.Command, args), nil
 	case "exit":
-		repl.History.Close()
-		os.Exit(0)
-	default:
-		_, ok := repl.CmdExist(cmdStruct.Command)
-		if !ok {
-			return ErrCommandNotFound
+		status, ok := cmds.ExitArgs(repl, args)
+		// A background job only ends itself, like a subshell would
+		if ok && job == nil {
+			repl.Exit(status)
 		}
-		cmds.RunOSCmd(repl, cmdStruct.Command, args)
+		return status, nil
+	}
+
+	return 0, nil
+}
+
+// StatusForError maps errors that prevented a command from running to the
+// exit status a shell reports for them.
+func StatusForError(err error) int {
+	if errors.Is(err, ErrCommandNotFound) {
+		return 127
 	}
 
-	return nil
+	return 1
 }
@@ -0,0 +1,100 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/shell-starter-go/app/cmds"
+)
+
+func TestCdWithoutArguments(t *testing.T) {
+	home, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", home)
+	t.Setenv("HISTFILE", filepath.Join(home, "history"))
+
+	for _, script := range []string{"cd", "cd $GOSH_TEST_UNSET", "cd && cd $GOSH_TEST_UNSET"} {
+		t.Run(script, func(t *testing.T) {
+			t.Chdir(os.TempDir())
+
+			repl := cmds.InitRepl()
+			if status := RunScript(repl, "test", strings.NewReader(script+"\n")); status != 0 {
+				t.Fatalf("status = %d", status)
+			}
+
+			dir, _ := os.Getwd()
+			if got, _ := filepath.EvalSymlinks(dir); got != home {
+				t.Errorf("cwd = %q, want %q", got, home)
+			}
+		})
+	}
+}
+
+func TestAssignments(t *testing.T) {
+	t.Setenv("HISTFILE", filepath.Join(t.TempDir(), "history"))
+	t.Setenv("GOSH_TEST_EXPORTED", "old")
+
+	tests := []struct {
+		script string
+		name   string
+		value  string
+		ok     bool
+	}{
+		{"X=1", "X", "1", true},
+		{"X='a  b'; Y=$X", "Y", "a  b", true},
+		{"X=1 Y=$X", "Y", "1", true},
+		{"X=1 true", "X", "", false},
+		{"X=1 | cat", "X", "", false},
+		{"echo X=1", "X", "", false},
+		{"X=1; X=2 true", "X", "1", true},
+	}
+
+	for _, test := range tests {
+		repl := cmds.InitRepl()
+		if status := RunScript(repl, "test", strings.NewReader(test.script)); status != 0 {
+			t.Errorf("%s: status = %d", test.script, status)
+		}
+		if value, ok := repl.LookupVar(test.name); value != test.value || ok != test.ok {
+			t.Errorf("%s: $%s = %q, %v, want %q, %v", test.script, test.name, value, ok, test.value, test.ok)
+		}
+	}
+
+	// Only exported variables reach the environment
+	repl := cmds.InitRepl()
+	script := "GOSH_TEST_SHELL=1; GOSH_TEST_EXPORTED=new; export GOSH_TEST_LATER; GOSH_TEST_LATER=2"
+	if status := RunScript(repl, "test", strings.NewReader(script)); status != 0 {
+		t.Fatalf("status = %d", status)
+	}
+	t.Cleanup(func() { os.Unsetenv("GOSH_TEST_LATER") })
+	for name, want := range map[string]string{"GOSH_TEST_SHELL": "", "GOSH_TEST_EXPORTED": "new", "GOSH_TEST_LATER": "2"} {
+		if got := os.Getenv(name); got != want {
+			t.Errorf("env %s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestPathChange(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HISTFILE", filepath.Join(dir, "history"))
+	if err := os.WriteFile(filepath.Join(dir, "gosh-test-cmd"), []byte("#!/bin/sh\nexit 3\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	repl := cmds.InitRepl()
+	if status := RunScript(repl, "test", strings.NewReader("gosh-test-cmd")); status != 127 {
+		t.Errorf("before: status = %d, want 127", status)
+	}
+
+	t.Setenv("PATH", os.Getenv("PATH"))
+	script := "PATH=" + dir + ":$PATH; gosh-test-cmd"
+	if status := RunScript(repl, "test", strings.NewReader(script)); status != 3 {
+		t.Errorf("after: status = %d, want 3", status)
+	}
+	if _, ok := repl.CmdExist("gosh-test-cmd"); !ok {
+		t.Error("gosh-test-cmd not found in the new PATH")
+	}
+}
@@ -1,56 +1,156 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/codecrafters-io/shell-starter-go/app/cmds"
+	"github.com/codecrafters-io/shell-starter-go/app/internal/args"
+	"github.com/codecrafters-io/shell-starter-go/app/internal/expand"
 	"github.com/codecrafters-io/shell-starter-go/app/internal/reader"
 	"github.com/codecrafters-io/shell-starter-go/app/internal/runner"
+	"golang.org/x/term"
 )
 
 func main() {
+	opts, err := args.ParseArgs(os.Args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+		os.Exit(2)
+	}
+
 	repl := cmds.InitRepl()
-	defer repl.History.Close()
+	repl.SetPositional(opts.Name, opts.Params)
+
+	if opts.ReadsStdin() && term.IsTerminal(int(os.Stdin.Fd())) {
+		repl.Jobs.HandleSignals()
+		if err := repl.History.Load(); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+		}
+		// A command that exits the shell is still saved
+		repl.OnExit(func() { repl.History.Finish(repl.LastStatus()) })
+		loadInputrc(repl)
+		if !opts.NoRC {
+			loadRC(repl)
+		}
+		runInteractive(repl)
+		return
+	}
+
+	// Scripts run their commands in the shell's own process group
+	repl.Jobs.Control = false
+
+	switch {
+	case opts.Command != nil:
+		runScript(repl, opts.Name, strings.NewReader(*opts.Command))
+	case opts.Script != "":
+		file, err := os.Open(opts.Script)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s: No such file or directory\n", os.Args[0], opts.Script)
+			repl.Exit(127)
+		}
+		defer file.Close()
+		runScript(repl, opts.Script, file)
+	default:
+		runScript(repl, opts.Name, os.Stdin)
+	}
+}
+
+// loadInputrc reads the key bindings in $INPUTRC, or else ~/.inputrc.
+// Like the rc file, only a file named explicitly has its errors reported.
+func loadInputrc(repl *cmds.Repl) {
+	path, explicit := os.LookupEnv("INPUTRC")
+	if !explicit {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return
+		}
+		path = filepath.Join(home, ".inputrc")
+	}
+
+	errs := repl.ReadInputrc(path)
+	if explicit {
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+		}
+	}
+}
+
+// loadRC sources the user's rc file, $GOSH_RC or ~/.gosh_rc, before the
+// first prompt. A missing default file is not an error.
+func loadRC(repl *cmds.Repl) {
+	path, explicit := os.LookupEnv("GOSH_RC")
+	if !explicit {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return
+		}
+		path = filepath.Join(home, ".gosh_rc")
+	}
+
+	_, err := runner.SourceFile(repl, path)
+	if err != nil && explicit {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[0], err)
+	}
+}
+
+func runInteractive(repl *cmds.Repl) {
+	// One reader for the session, so that the kill ring is kept
+	streamReader := reader.NewStreamReader(repl.GetTrieNode(), repl.History, repl.Options(), repl.Keymaps)
+	streamReader.OnCommand(func(command string) {
+		runner.RunScript(repl, "bind", strings.NewReader(command))
+	})
 
 	for {
 		repl.ResetOutput()
-		streamReader := reader.NewStreamReader(repl.GetTrieNode(), repl.History)
+		repl.Jobs.Notify(os.Stderr)
+		if repl.Options().ShareHistory {
+			repl.History.ReadNew()
+		}
 
 		fmt.Fprint(os.Stdout, "$ ")
 
-		cmdPipe, err := streamReader.ReadCommand()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading command: %v\n", err)
+		cmdList, err := streamReader.ReadCommand()
+		if errors.Is(err, reader.ErrInterrupted) {
+			repl.SetLastStatus(130)
 			continue
 		}
-
-		if cmdPipe == nil {
+		if errors.Is(err, reader.ErrSyntax) {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			repl.SetLastStatus(2)
 			continue
 		}
-
-		if len(cmdPipe.Cmds) == 0 {
+		if errors.Is(err, expand.ErrHistory) {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			repl.SetLastStatus(1)
 			continue
 		}
-
-		// Write command to history for single commands
-		if len(cmdPipe.Cmds) == 1 {
-			cmd := cmdPipe.Cmds[0]
-			repl.History.Write(fmt.Sprintf("%s %v", cmd.Command, strings.Join(cmd.Args, " ")))
-		} else {
-			// Write the entire pipe command to history
-			var cmdStrings []string
-			for _, cmd := range cmdPipe.Cmds {
-				cmdStrings = append(cmdStrings, fmt.Sprintf("%s %s", cmd.Command, strings.Join(cmd.Args, " ")))
-			}
-			repl.History.Write(strings.Join(cmdStrings, " | "))
+		if errors.Is(err, io.EOF) {
+			fmt.Fprintln(os.Stderr, "exit")
+			repl.Exit(repl.LastStatus())
 		}
-
-		// Handle both single commands and pipes uniformly
-		err = runner.RunPipeCmdsV2(repl, cmdPipe)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "%v: %v\n", cmdPipe.Cmds[0].Command, err)
+			// The terminal is gone, there is nothing left to read
+			fmt.Fprintf(os.Stderr, "Error reading command: %v\n", err)
+			repl.Exit(1)
+		}
+
+		if cmdList == nil {
+			continue
 		}
+
+		repl.History.Start(cmdList.Source)
+		runner.RunCmdList(repl, cmdList)
+		repl.History.Finish(repl.LastStatus())
 	}
 }
+
+// runScript runs every command read from input and exits with the status of
+// the last one.
+func runScript(repl *cmds.Repl, name string, input io.Reader) {
+	repl.Exit(runner.RunScript(repl, name, input))
+}
@@ -4,4 +4,4 @@ go 1.24.0
 
 require golang.org/x/term v0.32.0
 
-require golang.org/x/sys v0.33.0 // indirect
+require golang.org/x/sys v0.33.0